@@ -0,0 +1,6 @@
+
+package config
+
+// AdminEmail es el email de la cuenta que se promueve a administrador al arrancar
+// si todavía no existe ningún administrador
+var AdminEmail = getEnv("ADMIN_EMAIL", "")
@@ -0,0 +1,8 @@
+package config
+
+// AvatarMaxBytes es el tamaño máximo de la imagen que se puede subir como avatar
+var AvatarMaxBytes = getEnvInt("AVATAR_MAX_BYTES", 2*1024*1024)
+
+// AvatarMaxPixeles es el ancho o alto máximo de la imagen original, evita que una imagen chica
+// comprimida ocupe demasiada memoria al decodificarla
+var AvatarMaxPixeles = getEnvInt("AVATAR_MAX_PIXELS", 4096)
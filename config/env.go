@@ -0,0 +1,56 @@
+
+package config
+
+import (
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// getEnv lee una variable de entorno y si no existe regresa el valor por defecto
+func getEnv(clave, porDefecto string) string {
+    if valor, ok := os.LookupEnv(clave); ok && valor != "" {
+        return valor
+    }
+    return porDefecto
+}
+
+// getEnvInt igual que getEnv pero para enteros
+func getEnvInt(clave string, porDefecto int) int {
+    if valor, err := strconv.Atoi(getEnv(clave, "")); err == nil {
+        return valor
+    }
+    return porDefecto
+}
+
+// getEnvDuration igual que getEnv pero para duraciones ("15m", "24h", ...)
+func getEnvDuration(clave string, porDefecto time.Duration) time.Duration {
+    if valor, err := time.ParseDuration(getEnv(clave, "")); err == nil {
+        return valor
+    }
+    return porDefecto
+}
+
+// getEnvBool igual que getEnv pero para booleanos ("true", "false", "1", "0")
+func getEnvBool(clave string, porDefecto bool) bool {
+    if valor, err := strconv.ParseBool(getEnv(clave, "")); err == nil {
+        return valor
+    }
+    return porDefecto
+}
+
+// getEnvList lee una lista separada por comas, sin espacios ni elementos vacíos y en minúsculas
+func getEnvList(clave string, porDefecto []string) []string {
+    valor := getEnv(clave, "")
+    if valor == "" {
+        return porDefecto
+    }
+    var lista []string
+    for _, elemento := range strings.Split(valor, ",") {
+        if elemento = strings.ToLower(strings.TrimSpace(elemento)); elemento != "" {
+            lista = append(lista, elemento)
+        }
+    }
+    return lista
+}
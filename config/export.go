@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// Exportación de datos personales, se puede cambiar con variables de entorno
+
+// ExportMaxSincrono es cuantas tasks puede tener una cuenta para que el zip se regrese en la
+// misma petición, arriba de eso se genera en segundo plano
+var ExportMaxSincrono = getEnvInt("EXPORT_MAX_SYNC_TASKS", 500)
+
+// ExportDuracion es cuanto tiempo se puede descargar una exportación antes de borrarse
+var ExportDuracion = getEnvDuration("EXPORT_TTL", 24*time.Hour)
+
+// ExportTimeout es el tiempo máximo para generar una exportación en segundo plano
+var ExportTimeout = getEnvDuration("EXPORT_TIMEOUT", 10*time.Minute)
@@ -0,0 +1,15 @@
+
+package config
+
+// JWTKeysDir es la carpeta con las llaves para firmar los JWT. Cada archivo "<kid>.pem"
+// es una llave privada (RSA o Ed25519) y cada "<kid>.pub.pem" una llave pública de una
+// llave ya retirada que solo se usa para verificar tokens viejos
+var JWTKeysDir = getEnv("JWT_KEYS_DIR", "keys")
+
+// JWTActiveKID es el kid de la llave con la que se firman los tokens nuevos,
+// si está vacío se usa la última llave privada en orden alfabético
+var JWTActiveKID = getEnv("JWT_ACTIVE_KID", "")
+
+// JWTKeysDev permite arrancar sin llaves: se genera una llave temporal que cambia en cada
+// reinicio, solo para desarrollo. Sin esta opción el servidor no arranca si no hay llaves
+var JWTKeysDev = getEnvBool("JWT_KEYS_DEV", false)
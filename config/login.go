@@ -0,0 +1,24 @@
+
+package config
+
+import "time"
+
+// Límites contra fuerza bruta en el login, todos se pueden cambiar con variables de entorno
+
+// LoginMaxFallos es cuantos fallos seguidos se permiten por email antes de bloquear la cuenta
+var LoginMaxFallos = getEnvInt("LOGIN_MAX_FALLOS", 5)
+
+// LoginMaxFallosIP es cuantos fallos se permiten desde una misma IP antes de bloquearla
+var LoginMaxFallosIP = getEnvInt("LOGIN_MAX_FALLOS_IP", 20)
+
+// LoginBloqueo es cuanto dura el bloqueo al llegar al máximo de fallos
+var LoginBloqueo = getEnvDuration("LOGIN_BLOQUEO", 15*time.Minute)
+
+// LoginBackoffBase es la espera después del primer fallo, se duplica en cada fallo siguiente
+var LoginBackoffBase = getEnvDuration("LOGIN_BACKOFF_BASE", time.Second)
+
+// LoginBackoffMax es la espera máxima entre intentos antes de llegar al bloqueo
+var LoginBackoffMax = getEnvDuration("LOGIN_BACKOFF_MAX", 5*time.Minute)
+
+// LoginVentana es cuanto tiempo sin fallos tiene que pasar para que el contador vuelva a cero
+var LoginVentana = getEnvDuration("LOGIN_VENTANA", time.Hour)
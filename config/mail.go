@@ -0,0 +1,34 @@
+
+package config
+
+// Configuración del envío de correos
+
+// MailerTipo elige la implementación: "smtp", "file", "console" o "memory"
+var MailerTipo = getEnv("MAILER", "console")
+
+// MailerDir es la carpeta donde el mailer "file" guarda los correos como .eml
+var MailerDir = getEnv("MAILER_DIR", "mails")
+
+// MailRemitente es la dirección que aparece como remitente
+var MailRemitente = getEnv("MAIL_FROM", "no-reply@localhost")
+
+// Datos del servidor SMTP para el mailer "smtp"
+var (
+    SMTPHost     = getEnv("SMTP_HOST", "localhost")
+    SMTPPuerto   = getEnvInt("SMTP_PORT", 587)
+    SMTPUsuario  = getEnv("SMTP_USER", "")
+    SMTPPassword = getEnv("SMTP_PASSWORD", "")
+)
+
+// AppURL es la URL pública de la API, se usa para armar los enlaces de los correos
+var AppURL = getEnv("APP_URL", "http://localhost:3000")
+
+// Políticas para las cuentas que todavía no verifican su email
+const (
+    VerificacionOff       = "off"      // no se pide verificar el email
+    VerificacionRestringe = "restrict" // pueden entrar pero no crear ni modificar datos
+    VerificacionBloquea   = "block"    // no pueden iniciar sesión
+)
+
+// EmailVerificacion es la política activa (EMAIL_VERIFICATION)
+var EmailVerificacion = getEnv("EMAIL_VERIFICATION", VerificacionRestringe)
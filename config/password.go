@@ -0,0 +1,16 @@
+
+package config
+
+// Política de contraseñas, se aplica al cambiar o recuperar la contraseña
+
+// PasswordLongitudMinima es el mínimo de caracteres
+var PasswordLongitudMinima = getEnvInt("PASSWORD_MIN_LENGTH", 8)
+
+// PasswordRequiereMayusculas pide al menos una mayúscula y una minúscula
+var PasswordRequiereMayusculas = getEnvBool("PASSWORD_REQUIRE_MIXED_CASE", true)
+
+// PasswordRequiereNumero pide al menos un dígito
+var PasswordRequiereNumero = getEnvBool("PASSWORD_REQUIRE_DIGIT", true)
+
+// PasswordRequiereSimbolo pide al menos un caracter que no sea letra ni número
+var PasswordRequiereSimbolo = getEnvBool("PASSWORD_REQUIRE_SYMBOL", false)
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// Borrado lógico de cuentas, se puede cambiar con variables de entorno
+
+// PurgaGracia es cuanto tiempo se conserva una cuenta eliminada antes de borrarla para siempre,
+// mientras no pase se puede restaurar
+var PurgaGracia = getEnvDuration("PURGE_GRACE_PERIOD", 30*24*time.Hour)
+
+// PurgaIntervalo es cada cuanto corre el proceso que borra las cuentas vencidas
+var PurgaIntervalo = getEnvDuration("PURGE_INTERVAL", time.Hour)
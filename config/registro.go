@@ -0,0 +1,20 @@
+package config
+
+// Reglas de registro de cuentas nuevas, todas se pueden cambiar con variables de entorno
+
+// RegistroDominiosPermitidos si no está vacía solo se aceptan emails de estos dominios ("empresa.com,otra.mx")
+var RegistroDominiosPermitidos = getEnvList("REGISTRO_DOMINIOS_PERMITIDOS", nil)
+
+// RegistroDominiosBloqueados son dominios que nunca se aceptan, por ejemplo de correos desechables
+var RegistroDominiosBloqueados = getEnvList("REGISTRO_DOMINIOS_BLOQUEADOS", nil)
+
+// RegistroEdadMinima es la edad mínima en años, calculada con la fecha de nacimiento (0 la desactiva)
+var RegistroEdadMinima = getEnvInt("REGISTRO_EDAD_MINIMA", 13)
+
+// RegistroSoloInvitacion hace que solo se pueda registrar con un código de invitación
+var RegistroSoloInvitacion = getEnvBool("REGISTRO_SOLO_INVITACION", false)
+
+// RegistroCamposRequeridos son los campos que no pueden venir vacíos, email y password siempre lo son
+var RegistroCamposRequeridos = getEnvList("REGISTRO_CAMPOS_REQUERIDOS", []string{
+    "nombre", "fecha_nacimiento", "pregunta_secreta", "respuesta_secreta",
+})
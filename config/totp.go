@@ -0,0 +1,5 @@
+
+package config
+
+// TOTPEmisor es el nombre que aparece en la app de autenticación junto al email
+var TOTPEmisor = getEnv("TOTP_EMISOR", "api-rest-go")
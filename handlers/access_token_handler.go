@@ -0,0 +1,148 @@
+// handlers/access_token_handler.go
+package handlers
+
+import (
+    "context"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// getCollectionAccessTokens devuelve la colección "access_tokens"
+func getCollectionAccessTokens() *mongo.Collection {
+    return config.ClientMongo.Database(config.DBName).Collection("access_tokens")
+}
+
+// scopeValido indica si el scope existe
+func scopeValido(scope string) bool {
+    for _, s := range models.ScopesValidos {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// CreateAccessToken crea un token de acceso personal, el valor completo solo se regresa aquí
+func CreateAccessToken(c *fiber.Ctx) error {
+    type Request struct {
+        Nombre     string   `json:"nombre"`
+        Scopes     []string `json:"scopes"`
+        ExpiraDias int      `json:"expira_dias"` // 0 = no expira
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    if body.Nombre == "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "El nombre es obligatorio"})
+    }
+    if len(body.Scopes) == 0 {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Se necesita al menos un scope"})
+    }
+    for _, scope := range body.Scopes {
+        if !scopeValido(scope) {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Scope inválido: " + scope, "scopes_validos": models.ScopesValidos})
+        }
+    }
+    if body.ExpiraDias < 0 {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expira_dias inválido"})
+    }
+
+    userObjID, err := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+
+    token, prefijo, hash, err := utils.GenerarAccessToken()
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+    }
+
+    ahora := time.Now()
+    pat := models.AccessToken{
+        ID:        primitive.NewObjectID(),
+        UsuarioID: userObjID,
+        Nombre:    body.Nombre,
+        Prefijo:   prefijo,
+        TokenHash: hash,
+        Scopes:    body.Scopes,
+        CreadoEn:  ahora,
+    }
+    if body.ExpiraDias > 0 {
+        expira := ahora.AddDate(0, 0, body.ExpiraDias)
+        pat.ExpiraEn = &expira
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    if _, err := getCollectionAccessTokens().InsertOne(ctx, pat); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al crear token"})
+    }
+    return c.Status(fiber.StatusCreated).JSON(fiber.Map{"token": token, "access_token": pat})
+}
+
+// GetAccessTokens lista los tokens personales del usuario (sin el valor del token)
+func GetAccessTokens(c *fiber.Ctx) error {
+    userObjID, err := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+
+    col := getCollectionAccessTokens()
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "creado_en", Value: -1}})
+    cursor, err := col.Find(ctx, bson.M{"usuario_id": userObjID, "revocado": false}, opts)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al listar tokens"})
+    }
+    defer cursor.Close(ctx)
+
+    tokens := []models.AccessToken{}
+    if err := cursor.All(ctx, &tokens); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer tokens"})
+    }
+    return c.JSON(tokens)
+}
+
+// RevokeAccessToken revoca un token personal del usuario
+func RevokeAccessToken(c *fiber.Ctx) error {
+    tokenID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+    userObjID, _ := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    filter := bson.M{"_id": tokenID, "usuario_id": userObjID, "revocado": false}
+    result, err := getCollectionAccessTokens().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revocado": true}})
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo revocar el token"})
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Token no encontrado"})
+    }
+    return c.JSON(fiber.Map{"message": "Token revocado exitosamente"})
+}
+
+// crearIndicesAccessTokens crea los índices de "access_tokens"
+func crearIndicesAccessTokens(ctx context.Context) error {
+    _, err := getCollectionAccessTokens().Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "usuario_id", Value: 1}, {Key: "creado_en", Value: -1}}},
+    })
+    return err
+}
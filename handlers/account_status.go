@@ -0,0 +1,146 @@
+// handlers/account_status.go
+package handlers
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// longitudMaximaMotivo es el máximo de caracteres del motivo de un cambio de estado
+const longitudMaximaMotivo = 500
+
+// SuspendUser suspende o bloquea una cuenta (solo administradores). El body lleva el motivo y
+// opcionalmente "status": "locked" para bloquearla en lugar de suspenderla. Todas las sesiones
+// y tokens que ya tenía la cuenta dejan de funcionar
+func SuspendUser(c *fiber.Ctx) error {
+    type Request struct {
+        Estado string `json:"status"`
+        Motivo string `json:"motivo"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    if body.Estado == "" {
+        body.Estado = models.EstadoSuspendido
+    }
+
+    errores := erroresCampo{}
+    if body.Estado != models.EstadoSuspendido && body.Estado != models.EstadoBloqueado {
+        errores.agregar("status", "Debe ser suspended o locked")
+    }
+    validarMotivo(body.Motivo, errores)
+    if c.Params("id") == c.Locals("userID").(string) {
+        errores.agregar("id", "No puedes suspender tu propia cuenta")
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+    return responderCambioEstado(c, body.Estado, body.Motivo)
+}
+
+// ReactivateUser vuelve a activar una cuenta suspendida o bloqueada (solo administradores).
+// Las sesiones anteriores no se recuperan, el usuario tiene que iniciar sesión otra vez
+func ReactivateUser(c *fiber.Ctx) error {
+    type Request struct {
+        Motivo string `json:"motivo"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    errores := erroresCampo{}
+    validarMotivo(body.Motivo, errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+    return responderCambioEstado(c, models.EstadoActivo, body.Motivo)
+}
+
+// validarMotivo exige un motivo para cada cambio de estado, queda en el historial de la cuenta
+func validarMotivo(motivo string, errores erroresCampo) {
+    motivo = strings.TrimSpace(motivo)
+    if motivo == "" {
+        errores.agregar("motivo", "El motivo es obligatorio")
+    } else if len([]rune(motivo)) > longitudMaximaMotivo {
+        errores.agregar("motivo", "El motivo es demasiado largo")
+    }
+}
+
+// responderCambioEstado aplica el cambio de estado que pidió el admin sobre la cuenta :id
+func responderCambioEstado(c *fiber.Ctx, estado, motivo string) error {
+    objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+    adminID, err := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := cambiarEstado(ctx, objectID, estado, strings.TrimSpace(motivo), &adminID)
+    if err == mongo.ErrNoDocuments {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if err == errMismoEstado {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La cuenta ya tiene ese estado"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo cambiar el estado de la cuenta"})
+    }
+    return c.JSON(models.NewUserResponse(user, models.VistaAdmin))
+}
+
+// errMismoEstado se regresa cuando la cuenta ya tiene el estado que se pide
+var errMismoEstado = errors.New("la cuenta ya tiene ese estado")
+
+// cambiarEstado guarda el nuevo estado y agrega el cambio al historial. En cualquier cambio se
+// cierran las sesiones, eso también actualiza el cache de sesión del middleware para que el
+// estado nuevo aplique al momento. Los tokens personales se rechazan mientras la cuenta no esté
+// activa porque el middleware revisa el estado en cada petición
+func cambiarEstado(ctx context.Context, userID primitive.ObjectID, estado, motivo string, adminID *primitive.ObjectID) (models.User, error) {
+    var user models.User
+    cambio := models.CambioEstado{Estado: estado, Motivo: motivo, AdminID: adminID, Fecha: time.Now().UTC()}
+
+    // Las cuentas sin el campo status están activas, por eso se compara con $nin y no con $ne
+    filter := bson.M{"_id": userID, "deleted_at": nil, "status": bson.M{"$ne": estado}}
+    if estado == models.EstadoActivo {
+        filter["status"] = bson.M{"$nin": bson.A{models.EstadoActivo, nil}}
+    }
+    update := bson.M{"$set": bson.M{"status": estado}, "$push": bson.M{"historial_estado": cambio}}
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+    err := getCollectionUsers().FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        if _, errBuscar := buscarUsuarioPorID(ctx, userID.Hex()); errBuscar == nil {
+            return user, errMismoEstado
+        }
+        return user, err
+    }
+    if err != nil {
+        return user, err
+    }
+
+    return user, cerrarSesiones(ctx, userID)
+}
+
+// responderEstadoCuenta contesta 403 si la cuenta no está activa, se usa al emitir tokens nuevos
+func responderEstadoCuenta(c *fiber.Ctx, user models.User) (bool, error) {
+    if models.CuentaActiva(user.Estado) {
+        return false, nil
+    }
+    return true, c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": models.MensajeEstado(user.Estado)})
+}
@@ -0,0 +1,87 @@
+// handlers/admin_handler.go
+package handlers
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// BootstrapAdmin promueve a administrador la cuenta de config.AdminEmail, solo si
+// todavía no hay ningún administrador. Se llama una vez al arrancar
+func BootstrapAdmin() {
+    col := getCollectionUsers()
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    admins, err := col.CountDocuments(ctx, bson.M{"rol": models.RolAdmin, "deleted_at": nil})
+    if err != nil {
+        log.Fatal("Error al buscar administradores: ", err)
+    }
+    if admins > 0 {
+        return
+    }
+    if config.AdminEmail == "" {
+        log.Println("Aviso: no hay ningún administrador, define ADMIN_EMAIL para promover una cuenta")
+        return
+    }
+
+    opts := options.Update().SetCollation(collationEmail)
+    result, err := col.UpdateOne(ctx, bson.M{"email": normalizarEmail(config.AdminEmail), "deleted_at": nil}, bson.M{"$set": bson.M{"rol": models.RolAdmin}}, opts)
+    if err != nil {
+        log.Fatal("Error al promover administrador: ", err)
+    }
+    if result.MatchedCount == 0 {
+        log.Println("Aviso: ADMIN_EMAIL", config.AdminEmail, "no está registrado todavía")
+        return
+    }
+    log.Println("Cuenta", config.AdminEmail, "promovida a administrador")
+}
+
+// SetUserRole cambia el rol de un usuario (solo administradores). Se cierran sus sesiones
+// para que los tokens con el rol anterior dejen de funcionar
+func SetUserRole(c *fiber.Ctx) error {
+    idParam := c.Params("id")
+    objectID, err := primitive.ObjectIDFromHex(idParam)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    type Request struct {
+        Rol string `json:"rol"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    if body.Rol != models.RolAdmin && body.Rol != models.RolUsuario {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Rol inválido"})
+    }
+    if idParam == c.Locals("userID").(string) && body.Rol != models.RolAdmin {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No puedes quitarte el rol de administrador"})
+    }
+
+    col := getCollectionUsers()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    result, err := col.UpdateOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}, bson.M{"$set": bson.M{"rol": body.Rol}})
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar el rol"})
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if err := cerrarSesiones(ctx, objectID); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudieron cerrar las sesiones"})
+    }
+    return c.JSON(fiber.Map{"message": "Rol actualizado exitosamente"})
+}
@@ -0,0 +1,258 @@
+// handlers/avatar_handler.go
+package handlers
+
+import (
+    "bytes"
+    "context"
+    "image"
+    _ "image/gif"
+    "image/jpeg"
+    "image/png"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/gridfs"
+    "go.mongodb.org/mongo-driver/mongo/options"
+    "golang.org/x/image/draw"
+    _ "golang.org/x/image/webp"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// tiposAvatar son los formatos que se aceptan, se revisa el contenido y no el Content-Type del cliente
+var tiposAvatar = map[string]bool{
+    "image/jpeg": true,
+    "image/png":  true,
+    "image/gif":  true,
+    "image/webp": true,
+}
+
+// bucketAvatars devuelve el bucket de GridFS donde se guardan los avatares
+func bucketAvatars() (*gridfs.Bucket, error) {
+    return gridfs.NewBucket(config.ClientMongo.Database(config.DBName), options.GridFSBucket().SetName("avatars"))
+}
+
+// UploadAvatar sube la foto de perfil del usuario :id (campo "avatar" de un form multipart).
+// La imagen se recorta al centro y se guarda en cada tamaño de models.AvatarTamanos
+func UploadAvatar(c *fiber.Ctx) error {
+    objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    archivo, err := c.FormFile("avatar")
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Falta la imagen en el campo avatar"})
+    }
+    if archivo.Size > int64(config.AvatarMaxBytes) {
+        return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "La imagen supera el tamaño máximo permitido"})
+    }
+    f, err := archivo.Open()
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No se pudo leer la imagen"})
+    }
+    defer f.Close()
+    datos, err := io.ReadAll(io.LimitReader(f, int64(config.AvatarMaxBytes)+1))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No se pudo leer la imagen"})
+    }
+    if len(datos) > config.AvatarMaxBytes {
+        return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "La imagen supera el tamaño máximo permitido"})
+    }
+
+    if !tiposAvatar[http.DetectContentType(datos)] {
+        return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": "Formato no soportado, usa JPEG, PNG, GIF o WebP"})
+    }
+    // Se revisan las dimensiones antes de decodificar para no reservar memoria de más
+    cfg, formato, err := image.DecodeConfig(bytes.NewReader(datos))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La imagen está dañada o no es válida"})
+    }
+    if cfg.Width > config.AvatarMaxPixeles || cfg.Height > config.AvatarMaxPixeles {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La imagen es demasiado grande, máximo " + strconv.Itoa(config.AvatarMaxPixeles) + " pixeles por lado"})
+    }
+    img, _, err := image.Decode(bytes.NewReader(datos))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La imagen está dañada o no es válida"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+
+    user, err := buscarUsuarioPorID(ctx, objectID.Hex())
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+
+    avatar, err := guardarAvatar(user.ID, img, formato)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo guardar la imagen"})
+    }
+    result, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": user.ID, "deleted_at": nil}, bson.M{"$set": bson.M{"avatar": avatar}})
+    if err != nil || result.MatchedCount == 0 {
+        borrarArchivosAvatar(ctx, &avatar)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo guardar la imagen"})
+    }
+    borrarArchivosAvatar(ctx, user.Avatar)
+
+    user.Avatar = &avatar
+    return c.JSON(fiber.Map{"message": "Avatar actualizado exitosamente", "avatar_url": user.AvatarURL()})
+}
+
+// guardarAvatar genera cada tamaño del avatar y lo sube a GridFS. Las fotos JPEG se guardan
+// como JPEG y lo demás como PNG para no perder la transparencia
+func guardarAvatar(userID primitive.ObjectID, img image.Image, formato string) (models.Avatar, error) {
+    avatar := models.Avatar{
+        Version:       primitive.NewObjectID(),
+        ContentType:   "image/png",
+        Archivos:      map[string]primitive.ObjectID{},
+        ActualizadoEn: time.Now().UTC(),
+    }
+    if formato == "jpeg" {
+        avatar.ContentType = "image/jpeg"
+    }
+
+    bucket, err := bucketAvatars()
+    if err != nil {
+        return avatar, err
+    }
+    bucket.SetWriteDeadline(time.Now().Add(30 * time.Second))
+
+    for _, tamano := range models.AvatarTamanos {
+        var buf bytes.Buffer
+        escalada := escalarAvatar(img, tamano)
+        if avatar.ContentType == "image/jpeg" {
+            err = jpeg.Encode(&buf, escalada, &jpeg.Options{Quality: 85})
+        } else {
+            err = png.Encode(&buf, escalada)
+        }
+        if err != nil {
+            return avatar, err
+        }
+
+        nombre := userID.Hex() + "-" + strconv.Itoa(tamano)
+        opts := options.GridFSUpload().SetMetadata(bson.M{"usuario_id": userID, "content_type": avatar.ContentType, "tamano": tamano})
+        archivoID, err := bucket.UploadFromStream(nombre, &buf, opts)
+        if err != nil {
+            return avatar, err
+        }
+        avatar.Archivos[strconv.Itoa(tamano)] = archivoID
+    }
+    return avatar, nil
+}
+
+// escalarAvatar recorta el cuadrado central de la imagen y lo escala a tamano x tamano
+func escalarAvatar(img image.Image, tamano int) image.Image {
+    b := img.Bounds()
+    lado := b.Dx()
+    if b.Dy() < lado {
+        lado = b.Dy()
+    }
+    x := b.Min.X + (b.Dx()-lado)/2
+    y := b.Min.Y + (b.Dy()-lado)/2
+    recorte := image.Rect(x, y, x+lado, y+lado)
+
+    dst := image.NewRGBA(image.Rect(0, 0, tamano, tamano))
+    draw.CatmullRom.Scale(dst, dst.Bounds(), img, recorte, draw.Src, nil)
+    return dst
+}
+
+// GetAvatar descarga el avatar del usuario :id en el tamaño ?size (128 por defecto). La URL
+// con ?v= de avatar_url no cambia mientras no se suba otra foto, así que se puede guardar en caché
+func GetAvatar(c *fiber.Ctx) error {
+    objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+    tamano := c.QueryInt("size", models.AvatarTamanoDefault)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"avatar": 1})
+    filter := bson.M{"_id": objectID, "deleted_at": nil, "avatar": bson.M{"$exists": true}}
+    if err := getCollectionUsers().FindOne(ctx, filter, opts).Decode(&user); err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Avatar no encontrado"})
+    }
+    archivoID, ok := user.Avatar.Archivos[strconv.Itoa(tamano)]
+    if !ok {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tamaño no disponible"})
+    }
+
+    etag := `"` + user.Avatar.Version.Hex() + "-" + strconv.Itoa(tamano) + `"`
+    c.Set(fiber.HeaderETag, etag)
+    c.Set(fiber.HeaderLastModified, user.Avatar.ActualizadoEn.Format(http.TimeFormat))
+    if c.Query("v") == user.Avatar.Version.Hex() {
+        c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+    } else {
+        c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+    }
+    if c.Get(fiber.HeaderIfNoneMatch) == etag {
+        return c.SendStatus(fiber.StatusNotModified)
+    }
+
+    bucket, err := bucketAvatars()
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer el avatar"})
+    }
+    bucket.SetReadDeadline(time.Now().Add(30 * time.Second))
+    stream, err := bucket.OpenDownloadStream(archivoID)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Avatar no encontrado"})
+    }
+
+    // fasthttp cierra el stream cuando termina de mandarlo
+    c.Set(fiber.HeaderContentType, user.Avatar.ContentType)
+    return c.SendStream(stream, int(stream.GetFile().Length))
+}
+
+// DeleteAvatar quita la foto de perfil del usuario :id
+func DeleteAvatar(c *fiber.Ctx) error {
+    objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    var user models.User
+    filter := bson.M{"_id": objectID, "deleted_at": nil, "avatar": bson.M{"$exists": true}}
+    opts := options.FindOneAndUpdate().SetProjection(bson.M{"avatar": 1})
+    err = getCollectionUsers().FindOneAndUpdate(ctx, filter, bson.M{"$unset": bson.M{"avatar": ""}}, opts).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Avatar no encontrado"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo eliminar el avatar"})
+    }
+    borrarArchivosAvatar(ctx, user.Avatar)
+    return c.JSON(fiber.Map{"message": "Avatar eliminado exitosamente"})
+}
+
+// borrarArchivosAvatar borra de GridFS todos los tamaños de un avatar. Si algo falla solo se
+// registra, un archivo huérfano no afecta al usuario
+func borrarArchivosAvatar(ctx context.Context, avatar *models.Avatar) {
+    if avatar == nil {
+        return
+    }
+    bucket, err := bucketAvatars()
+    if err != nil {
+        log.Println("No se pudo abrir el bucket de avatares:", err)
+        return
+    }
+    for _, archivoID := range avatar.Archivos {
+        if err := bucket.DeleteContext(ctx, archivoID); err != nil && err != gridfs.ErrFileNotFound {
+            log.Println("No se pudo borrar el avatar", archivoID.Hex(), ":", err)
+        }
+    }
+}
@@ -0,0 +1,257 @@
+// handlers/checklist_handler.go
+package handlers
+
+import (
+    "context"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// longitudMaximaItem es el máximo de caracteres del texto de un elemento del checklist
+const longitudMaximaItem = 500
+
+// filtroTaskUsuario regresa el filtro de la task :id del usuario autenticado. Todas las rutas del
+// checklist pasan por aquí, así un elemento solo se toca a través de una task propia
+func filtroTaskUsuario(c *fiber.Ctx) (bson.M, bool) {
+    taskID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return nil, false
+    }
+    userObjID, err := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+    if err != nil {
+        return nil, false
+    }
+    return bson.M{"_id": taskID, "usuario_id": userObjID, "deleted_at": nil}, true
+}
+
+// AddChecklistItem agrega un elemento al checklist de la task :id, al final o en "posicion" (desde 0)
+func AddChecklistItem(c *fiber.Ctx) error {
+    type Request struct {
+        Texto    string `json:"texto"`
+        Posicion *int   `json:"posicion"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    filter, ok := filtroTaskUsuario(c)
+    if !ok {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, preferenciasUsuario(ctx, c.Locals("userID").(string)), errores)
+    body.Texto = strings.TrimSpace(body.Texto)
+    switch {
+    case body.Texto == "":
+        errores.agregar("texto", "El texto es obligatorio")
+    case len([]rune(body.Texto)) > longitudMaximaItem:
+        errores.agregar("texto", "El texto es demasiado largo")
+    }
+    if body.Posicion != nil && *body.Posicion < 0 {
+        errores.agregar("posicion", "No puede ser negativa")
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    item := models.ItemChecklist{ID: primitive.NewObjectID(), Texto: body.Texto}
+    push := bson.M{"$each": bson.A{item}}
+    if body.Posicion != nil {
+        push["$position"] = *body.Posicion
+    }
+    // El límite va en el filtro para que dos peticiones al mismo tiempo no lo rebasen
+    filter["checklist."+strconv.Itoa(models.MaxItemsChecklist-1)] = bson.M{"$exists": false}
+    task, err := actualizarChecklist(ctx, filter, bson.M{"$push": bson.M{"checklist": push}})
+    if err == mongo.ErrNoDocuments {
+        delete(filter, "checklist."+strconv.Itoa(models.MaxItemsChecklist-1))
+        if getCollectionTasks().FindOne(ctx, filter).Err() == nil {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "El checklist ya tiene el máximo de " + strconv.Itoa(models.MaxItemsChecklist) + " elementos"})
+        }
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar el checklist"})
+    }
+    return responderChecklist(c, task, loc, fiber.StatusCreated)
+}
+
+// ReorderChecklist cambia el orden del checklist de la task :id. "orden" lleva los ids de todos los
+// elementos en el orden nuevo
+func ReorderChecklist(c *fiber.Ctx) error {
+    type Request struct {
+        Orden []string `json:"orden"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    filter, ok := filtroTaskUsuario(c)
+    if !ok {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, preferenciasUsuario(ctx, c.Locals("userID").(string)), errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    col := getCollectionTasks()
+    var actual models.Task
+    if err := col.FindOne(ctx, filter).Decode(&actual); err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+    }
+
+    // El orden nuevo tiene que traer cada elemento exactamente una vez
+    porID := map[string]models.ItemChecklist{}
+    for _, item := range actual.Checklist {
+        porID[item.ID.Hex()] = item
+    }
+    nuevo := make([]models.ItemChecklist, 0, len(body.Orden))
+    for _, id := range body.Orden {
+        item, existe := porID[id]
+        if !existe {
+            return responderValidacion(c, erroresCampo{"orden": "El elemento " + id + " no está en el checklist o está repetido"})
+        }
+        delete(porID, id)
+        nuevo = append(nuevo, item)
+    }
+    if len(porID) > 0 {
+        return responderValidacion(c, erroresCampo{"orden": "Faltan elementos del checklist"})
+    }
+
+    // Si el checklist cambió desde que se leyó el orden ya no corresponde
+    filter["checklist"] = actual.Checklist
+    if actual.Checklist == nil {
+        filter["checklist"] = nil
+    }
+    task, err := actualizarChecklist(ctx, filter, bson.M{"$set": bson.M{"checklist": nuevo}})
+    if err == mongo.ErrNoDocuments {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "El checklist cambió mientras se actualizaba, intenta de nuevo"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar el checklist"})
+    }
+    return responderChecklist(c, task, loc, fiber.StatusOK)
+}
+
+// ToggleChecklistItem marca o desmarca como hecho el elemento :itemId de la task :id
+func ToggleChecklistItem(c *fiber.Ctx) error {
+    filter, itemID, ok := filtroItemChecklist(c)
+    if !ok {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, preferenciasUsuario(ctx, c.Locals("userID").(string)), errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    var actual models.Task
+    opts := options.FindOne().SetProjection(bson.M{"checklist.$": 1})
+    if err := getCollectionTasks().FindOne(ctx, filter, opts).Decode(&actual); err != nil || len(actual.Checklist) == 0 {
+        return responderItemNoEncontrado(c, ctx, filter)
+    }
+    hecho := !actual.Checklist[0].Hecho
+
+    // Se cambia solo si sigue como se leyó, así dos toggles al mismo tiempo no se anulan sin avisar
+    delete(filter, "checklist._id")
+    filter["checklist"] = bson.M{"$elemMatch": bson.M{"_id": itemID, "hecho": !hecho}}
+    set := bson.M{"checklist.$.hecho": hecho}
+    update := bson.M{"$set": set}
+    if hecho {
+        set["checklist.$.hecho_en"] = time.Now().UTC()
+    } else {
+        update["$unset"] = bson.M{"checklist.$.hecho_en": ""}
+    }
+    task, err := actualizarChecklist(ctx, filter, update)
+    if err == mongo.ErrNoDocuments {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "El checklist cambió mientras se actualizaba, intenta de nuevo"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar el checklist"})
+    }
+    return responderChecklist(c, task, loc, fiber.StatusOK)
+}
+
+// DeleteChecklistItem quita el elemento :itemId del checklist de la task :id
+func DeleteChecklistItem(c *fiber.Ctx) error {
+    filter, itemID, ok := filtroItemChecklist(c)
+    if !ok {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, preferenciasUsuario(ctx, c.Locals("userID").(string)), errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    task, err := actualizarChecklist(ctx, filter, bson.M{"$pull": bson.M{"checklist": bson.M{"_id": itemID}}})
+    if err == mongo.ErrNoDocuments {
+        return responderItemNoEncontrado(c, ctx, filter)
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar el checklist"})
+    }
+    return responderChecklist(c, task, loc, fiber.StatusOK)
+}
+
+// filtroItemChecklist regresa el filtro de la task :id del usuario que contiene el elemento :itemId
+func filtroItemChecklist(c *fiber.Ctx) (bson.M, primitive.ObjectID, bool) {
+    filter, ok := filtroTaskUsuario(c)
+    if !ok {
+        return nil, primitive.NilObjectID, false
+    }
+    itemID, err := primitive.ObjectIDFromHex(c.Params("itemId"))
+    if err != nil {
+        return nil, primitive.NilObjectID, false
+    }
+    filter["checklist._id"] = itemID
+    return filter, itemID, true
+}
+
+// responderItemNoEncontrado distingue entre una task que no es del usuario y un elemento que no existe
+func responderItemNoEncontrado(c *fiber.Ctx, ctx context.Context, filter bson.M) error {
+    delete(filter, "checklist._id")
+    if getCollectionTasks().FindOne(ctx, filter).Err() != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+    }
+    return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Elemento no encontrado"})
+}
+
+// actualizarChecklist aplica update a la task y la regresa ya actualizada
+func actualizarChecklist(ctx context.Context, filter, update bson.M) (models.Task, error) {
+    var task models.Task
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+    err := getCollectionTasks().FindOneAndUpdate(ctx, filter, update, opts).Decode(&task)
+    return task, err
+}
+
+// responderChecklist regresa la task con su checklist y el progreso, con las fechas en loc
+func responderChecklist(c *fiber.Ctx, task models.Task, loc *time.Location, status int) error {
+    return c.Status(status).JSON(fiber.Map{"message": "Checklist actualizado exitosamente", "task": models.NewTaskResponse(task).EnZona(loc)})
+}
@@ -0,0 +1,455 @@
+// handlers/export_handler.go
+package handlers
+
+import (
+    "archive/zip"
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/gridfs"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// getCollectionExports devuelve la colección "export_jobs"
+func getCollectionExports() *mongo.Collection {
+    return config.ClientMongo.Database(config.DBName).Collection("export_jobs")
+}
+
+// bucketExports devuelve el bucket de GridFS donde se guardan los zip de exportación
+func bucketExports() (*gridfs.Bucket, error) {
+    return gridfs.NewBucket(config.ClientMongo.Database(config.DBName), options.GridFSBucket().SetName("exports"))
+}
+
+// ExportMe genera un zip con todos los datos del usuario autenticado (perfil, tasks, tokens y
+// sesiones, en JSON y CSV). Si la cuenta es chica el zip se regresa directo, si no se crea un
+// trabajo en segundo plano y se responde 202 con la URL para consultar su estado
+func ExportMe(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+
+    total, err := getCollectionTasks().CountDocuments(ctx, bson.M{"usuario_id": user.ID})
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al contar tasks"})
+    }
+
+    if total <= int64(config.ExportMaxSincrono) {
+        var buf bytes.Buffer
+        if err := escribirExportacion(ctx, &buf, user); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo generar la exportación"})
+        }
+        c.Attachment(nombreExportacion(user))
+        return c.Send(buf.Bytes())
+    }
+
+    // Si ya hay una exportación en curso se regresa esa en lugar de crear otra
+    var job models.ExportJob
+    filter := bson.M{
+        "usuario_id": user.ID,
+        "estado":     models.ExportPendiente,
+        "creado_en":  bson.M{"$gt": time.Now().UTC().Add(-config.ExportTimeout)},
+    }
+    err = getCollectionExports().FindOne(ctx, filter).Decode(&job)
+    if err == mongo.ErrNoDocuments {
+        ahora := time.Now().UTC()
+        job = models.ExportJob{
+            ID:        primitive.NewObjectID(),
+            UsuarioID: user.ID,
+            Estado:    models.ExportPendiente,
+            CreadoEn:  ahora,
+            ExpiraEn:  ahora.Add(config.ExportDuracion),
+        }
+        if _, err := getCollectionExports().InsertOne(ctx, job); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo crear la exportación"})
+        }
+        go generarExportacion(job.ID, user)
+    } else if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al buscar exportaciones"})
+    }
+
+    c.Location(urlExportacion(job.ID))
+    return c.Status(fiber.StatusAccepted).JSON(respuestaExportacion(job))
+}
+
+// GetExport regresa el estado de una exportación del usuario, cuando está lista incluye la URL de descarga
+func GetExport(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    job, err := buscarExportacion(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Exportación no encontrada"})
+    }
+    return c.JSON(respuestaExportacion(job))
+}
+
+// DownloadExport descarga el zip de una exportación terminada
+func DownloadExport(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    job, err := buscarExportacion(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Exportación no encontrada"})
+    }
+    if job.Estado != models.ExportListo || job.ArchivoID == nil {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La exportación todavía no está lista"})
+    }
+
+    bucket, err := bucketExports()
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al abrir la exportación"})
+    }
+    bucket.SetReadDeadline(time.Now().Add(5 * time.Minute))
+    stream, err := bucket.OpenDownloadStream(*job.ArchivoID)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Exportación no encontrada"})
+    }
+
+    // fasthttp cierra el stream cuando termina de mandarlo
+    c.Attachment(stream.GetFile().Name)
+    return c.SendStream(stream, int(stream.GetFile().Length))
+}
+
+// buscarExportacion carga la exportación :id, solo si es del usuario autenticado y no ha expirado
+func buscarExportacion(ctx context.Context, c *fiber.Ctx) (models.ExportJob, error) {
+    var job models.ExportJob
+    jobID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return job, err
+    }
+    userObjID, err := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+    if err != nil {
+        return job, err
+    }
+    filter := bson.M{"_id": jobID, "usuario_id": userObjID, "expira_en": bson.M{"$gt": time.Now().UTC()}}
+    err = getCollectionExports().FindOne(ctx, filter).Decode(&job)
+    return job, err
+}
+
+// urlExportacion es la ruta para consultar el estado de una exportación
+func urlExportacion(id primitive.ObjectID) string {
+    return "/api/users/me/export/" + id.Hex()
+}
+
+// respuestaExportacion arma la respuesta con el estado y las URLs de la exportación
+func respuestaExportacion(job models.ExportJob) fiber.Map {
+    resp := fiber.Map{"export": job, "url": urlExportacion(job.ID)}
+    if job.Estado == models.ExportListo {
+        resp["download_url"] = urlExportacion(job.ID) + "/download"
+    }
+    return resp
+}
+
+// nombreExportacion es el nombre del zip que recibe el usuario
+func nombreExportacion(user models.User) string {
+    return fmt.Sprintf("export-%s-%s.zip", user.ID.Hex(), time.Now().UTC().Format("20060102"))
+}
+
+// generarExportacion corre en segundo plano: escribe el zip directo a GridFS y actualiza el
+// trabajo con el resultado. Si falla el trabajo queda en estado error con el motivo
+func generarExportacion(jobID primitive.ObjectID, user models.User) {
+    ctx, cancel := context.WithTimeout(context.Background(), config.ExportTimeout)
+    defer cancel()
+
+    set := bson.M{}
+    archivoID, tamano, err := subirExportacion(ctx, user)
+    if err != nil {
+        log.Println("Exportación", jobID.Hex(), "falló:", err)
+        set["estado"] = models.ExportError
+        set["error"] = "No se pudo generar la exportación"
+    } else {
+        set["estado"] = models.ExportListo
+        set["archivo_id"] = archivoID
+        set["tamano"] = tamano
+    }
+    set["terminado_en"] = time.Now().UTC()
+
+    if _, err := getCollectionExports().UpdateOne(ctx, bson.M{"_id": jobID}, bson.M{"$set": set}); err != nil {
+        log.Println("Exportación", jobID.Hex(), "no se pudo actualizar:", err)
+    }
+}
+
+// subirExportacion genera el zip del usuario dentro de un archivo de GridFS
+func subirExportacion(ctx context.Context, user models.User) (primitive.ObjectID, int64, error) {
+    bucket, err := bucketExports()
+    if err != nil {
+        return primitive.NilObjectID, 0, err
+    }
+    deadline, _ := ctx.Deadline()
+    bucket.SetWriteDeadline(deadline)
+
+    upload, err := bucket.OpenUploadStream(nombreExportacion(user))
+    if err != nil {
+        return primitive.NilObjectID, 0, err
+    }
+    contador := &contadorEscritura{w: upload}
+    if err := escribirExportacion(ctx, contador, user); err != nil {
+        upload.Abort()
+        return primitive.NilObjectID, 0, err
+    }
+    if err := upload.Close(); err != nil {
+        return primitive.NilObjectID, 0, err
+    }
+    return upload.FileID.(primitive.ObjectID), contador.n, nil
+}
+
+// contadorEscritura cuenta los bytes que se escriben, para guardar el tamaño del zip
+type contadorEscritura struct {
+    w io.Writer
+    n int64
+}
+
+func (c *contadorEscritura) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += int64(n)
+    return n, err
+}
+
+// escribirExportacion escribe el zip con todos los datos guardados del usuario. Las tasks se
+// leen con un cursor para no cargar las cuentas grandes completas en memoria
+func escribirExportacion(ctx context.Context, w io.Writer, user models.User) error {
+    zw := zip.NewWriter(w)
+    perfil := models.NewUserResponse(user, models.VistaAdmin)
+    tasksFilter := bson.M{"usuario_id": user.ID}
+
+    archivos := []struct {
+        nombre   string
+        escribir func(io.Writer) error
+    }{
+        {"export.json", func(f io.Writer) error {
+            return escribirJSON(f, fiber.Map{
+                "usuario_id":  user.ID.Hex(),
+                "generado_en": time.Now().UTC(),
+                "archivos":    []string{"perfil.json", "perfil.csv", "tasks.json", "tasks.csv", "tokens_acceso.json", "sesiones.json"},
+            })
+        }},
+        {"perfil.json", func(f io.Writer) error { return escribirJSON(f, perfil) }},
+        {"perfil.csv", func(f io.Writer) error { return escribirPerfilCSV(f, perfil) }},
+        {"tasks.json", func(f io.Writer) error { return escribirTasksJSON(ctx, f, tasksFilter) }},
+        {"tasks.csv", func(f io.Writer) error { return escribirTasksCSV(ctx, f, tasksFilter) }},
+        {"tokens_acceso.json", func(f io.Writer) error {
+            tokens := []models.AccessToken{}
+            return escribirColeccion(ctx, f, getCollectionAccessTokens(), bson.M{"usuario_id": user.ID}, &tokens)
+        }},
+        {"sesiones.json", func(f io.Writer) error {
+            sesiones := []models.RefreshToken{}
+            return escribirColeccion(ctx, f, getCollectionRefreshTokens(), bson.M{"usuario_id": user.ID}, &sesiones)
+        }},
+    }
+
+    for _, a := range archivos {
+        f, err := zw.Create(a.nombre)
+        if err != nil {
+            return err
+        }
+        if err := a.escribir(f); err != nil {
+            return fmt.Errorf("%s: %w", a.nombre, err)
+        }
+    }
+    return zw.Close()
+}
+
+// escribirJSON escribe v con sangría
+func escribirJSON(w io.Writer, v interface{}) error {
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(v)
+}
+
+// escribirColeccion escribe como arreglo JSON los documentos de col que cumplen filter.
+// Solo se usa para colecciones chicas; los campos json:"-" (hashes) no salen en el archivo
+func escribirColeccion(ctx context.Context, w io.Writer, col *mongo.Collection, filter bson.M, destino interface{}) error {
+    cursor, err := col.Find(ctx, filter)
+    if err != nil {
+        return err
+    }
+    if err := cursor.All(ctx, destino); err != nil {
+        return err
+    }
+    return escribirJSON(w, destino)
+}
+
+// escribirPerfilCSV escribe el perfil en dos columnas campo,valor
+func escribirPerfilCSV(w io.Writer, perfil models.UserResponse) error {
+    var campos map[string]interface{}
+    datos, err := json.Marshal(perfil)
+    if err != nil {
+        return err
+    }
+    if err := json.Unmarshal(datos, &campos); err != nil {
+        return err
+    }
+
+    cw := csv.NewWriter(w)
+    cw.Write([]string{"campo", "valor"})
+    for _, campo := range []string{"id", "nombre", "apellidos", "email", "fecha_nacimiento", "pregunta_secreta", "rol",
+        "email_verificado", "email_verificado_en", "totp_activo", "fecha_registro"} {
+        valor := ""
+        if v, ok := campos[campo]; ok {
+            valor = fmt.Sprint(v)
+        }
+        cw.Write([]string{campo, valor})
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// escribirTasksJSON escribe las tasks como arreglo JSON, una por una desde el cursor
+func escribirTasksJSON(ctx context.Context, w io.Writer, filter bson.M) error {
+    cursor, err := getCollectionTasks().Find(ctx, filter)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    if _, err := io.WriteString(w, "["); err != nil {
+        return err
+    }
+    for i := 0; cursor.Next(ctx); i++ {
+        var task models.Task
+        if err := cursor.Decode(&task); err != nil {
+            return err
+        }
+        datos, err := json.Marshal(task)
+        if err != nil {
+            return err
+        }
+        sep := "\n  "
+        if i > 0 {
+            sep = ",\n  "
+        }
+        if _, err := io.WriteString(w, sep); err != nil {
+            return err
+        }
+        if _, err := w.Write(datos); err != nil {
+            return err
+        }
+    }
+    if err := cursor.Err(); err != nil {
+        return err
+    }
+    _, err = io.WriteString(w, "\n]\n")
+    return err
+}
+
+// escribirTasksCSV escribe las tasks en CSV, las fechas en RFC 3339
+func escribirTasksCSV(ctx context.Context, w io.Writer, filter bson.M) error {
+    cursor, err := getCollectionTasks().Find(ctx, filter)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    cw := csv.NewWriter(w)
+    cw.Write([]string{"id", "titulo", "descripcion", "fecha_inicio", "fecha_final", "estado", "prioridad", "completed_at", "rrule", "deleted_at"})
+    for cursor.Next(ctx) {
+        var task models.Task
+        if err := cursor.Decode(&task); err != nil {
+            return err
+        }
+        completada, regla, eliminado := "", "", ""
+        if task.CompletadaEn != nil {
+            completada = task.CompletadaEn.Format(time.RFC3339)
+        }
+        if task.Recurrencia != nil {
+            regla = task.Recurrencia.Regla
+        }
+        if task.EliminadoEn != nil {
+            eliminado = task.EliminadoEn.Format(time.RFC3339)
+        }
+        cw.Write([]string{
+            task.ID.Hex(),
+            task.Titulo,
+            task.Descripcion,
+            task.FechaInicio.Format(time.RFC3339),
+            task.FechaFinal.Format(time.RFC3339),
+            task.Estado,
+            task.Prioridad,
+            completada,
+            regla,
+            eliminado,
+        })
+    }
+    if err := cursor.Err(); err != nil {
+        return err
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// borrarExportaciones borra los trabajos que cumplen filter junto con sus archivos en GridFS
+func borrarExportaciones(ctx context.Context, filter bson.M) (int, error) {
+    cursor, err := getCollectionExports().Find(ctx, filter)
+    if err != nil {
+        return 0, err
+    }
+    var jobs []models.ExportJob
+    if err := cursor.All(ctx, &jobs); err != nil {
+        return 0, err
+    }
+
+    bucket, err := bucketExports()
+    if err != nil {
+        return 0, err
+    }
+    borrados := 0
+    for _, job := range jobs {
+        if job.ArchivoID != nil {
+            if err := bucket.DeleteContext(ctx, *job.ArchivoID); err != nil && err != gridfs.ErrFileNotFound {
+                return borrados, err
+            }
+        }
+        if _, err := getCollectionExports().DeleteOne(ctx, bson.M{"_id": job.ID}); err != nil {
+            return borrados, err
+        }
+        borrados++
+    }
+    return borrados, nil
+}
+
+// purgarExportaciones borra las exportaciones que ya expiraron
+func purgarExportaciones() {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+    defer cancel()
+
+    borrados, err := borrarExportaciones(ctx, bson.M{"expira_en": bson.M{"$lt": time.Now().UTC()}})
+    if err != nil {
+        log.Println("Purga: error al borrar exportaciones expiradas:", err)
+    }
+    if borrados > 0 {
+        log.Println("Purga: se borraron", borrados, "exportaciones expiradas")
+    }
+}
+
+// crearIndicesExports crea los índices de export_jobs
+func crearIndicesExports(ctx context.Context) error {
+    _, err := getCollectionExports().Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "estado", Value: 1}},
+            Options: options.Index().SetName("usuario_estado"),
+        },
+        {
+            Keys:    bson.D{{Key: "expira_en", Value: 1}},
+            Options: options.Index().SetName("expira_en"),
+        },
+    })
+    return err
+}
@@ -0,0 +1,47 @@
+// handlers/indexes.go
+package handlers
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// CrearIndices crea los índices que necesitan las colecciones, se llama una vez al arrancar
+func CrearIndices() {
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+
+    if err := crearIndicesUsers(ctx); err != nil {
+        log.Fatal("Error al crear índices de users: ", err)
+    }
+    if err := crearIndicesListadoUsuarios(ctx); err != nil {
+        log.Fatal("Error al crear índices del listado de users: ", err)
+    }
+    if err := crearIndicesTasks(ctx); err != nil {
+        log.Fatal("Error al crear índices de tasks: ", err)
+    }
+    if err := crearIndicesRefreshTokens(ctx); err != nil {
+        log.Fatal("Error al crear índices de refresh_tokens: ", err)
+    }
+    if err := crearIndicesAccessTokens(ctx); err != nil {
+        log.Fatal("Error al crear índices de access_tokens: ", err)
+    }
+    if err := crearIndicesLoginAttempts(ctx); err != nil {
+        log.Fatal("Error al crear índices de login_attempts: ", err)
+    }
+    if err := crearIndicesInvitaciones(ctx); err != nil {
+        log.Fatal("Error al crear índices de invitations: ", err)
+    }
+    if err := crearIndicesExports(ctx); err != nil {
+        log.Fatal("Error al crear índices de export_jobs: ", err)
+    }
+    if err := crearIndicesEliminados(ctx); err != nil {
+        log.Fatal("Error al crear índices de borrado lógico: ", err)
+    }
+    if err := utils.CrearIndicesRevocacion(ctx); err != nil {
+        log.Fatal("Error al crear índices de revoked_tokens: ", err)
+    }
+}
@@ -0,0 +1,158 @@
+// handlers/invitation_handler.go
+package handlers
+
+import (
+    "context"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// getCollectionInvitaciones devuelve la colección "invitations"
+func getCollectionInvitaciones() *mongo.Collection {
+    return config.ClientMongo.Database(config.DBName).Collection("invitations")
+}
+
+// CreateInvitation crea un código de invitación (solo administradores). El código completo solo
+// se regresa aquí; se puede limitar a un email, a un número de usos y a unos días de validez
+func CreateInvitation(c *fiber.Ctx) error {
+    type Request struct {
+        Email      string `json:"email"`
+        UsosMax    int    `json:"usos_max"`    // 1 por defecto
+        ExpiraDias int    `json:"expira_dias"` // 0 = no expira
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    errores := erroresCampo{}
+    body.Email = normalizarEmail(body.Email)
+    if body.Email != "" && !emailValido(body.Email) {
+        errores.agregar("email", "Email inválido")
+    }
+    if body.UsosMax == 0 {
+        body.UsosMax = 1
+    }
+    if body.UsosMax < 0 {
+        errores.agregar("usos_max", "Debe ser mayor a cero")
+    }
+    if body.ExpiraDias < 0 {
+        errores.agregar("expira_dias", "No puede ser negativo")
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    adminID, err := primitive.ObjectIDFromHex(c.Locals("userID").(string))
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+    codigo, prefijo, hash, err := utils.GenerarCodigoInvitacion()
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar la invitación"})
+    }
+
+    ahora := time.Now().UTC()
+    inv := models.Invitacion{
+        ID:         primitive.NewObjectID(),
+        Prefijo:    prefijo,
+        CodigoHash: hash,
+        Email:      body.Email,
+        UsosMax:    body.UsosMax,
+        CreadoPor:  adminID,
+        CreadoEn:   ahora,
+    }
+    if body.ExpiraDias > 0 {
+        expira := ahora.AddDate(0, 0, body.ExpiraDias)
+        inv.ExpiraEn = &expira
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    if _, err := getCollectionInvitaciones().InsertOne(ctx, inv); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al crear la invitación"})
+    }
+    return c.Status(fiber.StatusCreated).JSON(fiber.Map{"codigo": codigo, "invitacion": inv})
+}
+
+// GetInvitations lista las invitaciones que no se han revocado (sin el código)
+func GetInvitations(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "creado_en", Value: -1}})
+    cursor, err := getCollectionInvitaciones().Find(ctx, bson.M{"revocada": false}, opts)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al listar invitaciones"})
+    }
+    defer cursor.Close(ctx)
+
+    invitaciones := []models.Invitacion{}
+    if err := cursor.All(ctx, &invitaciones); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer invitaciones"})
+    }
+    return c.JSON(invitaciones)
+}
+
+// RevokeInvitation revoca una invitación, los usuarios que ya se registraron con ella no cambian
+func RevokeInvitation(c *fiber.Ctx) error {
+    invID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    result, err := getCollectionInvitaciones().UpdateOne(ctx, bson.M{"_id": invID, "revocada": false}, bson.M{"$set": bson.M{"revocada": true}})
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo revocar la invitación"})
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Invitación no encontrada"})
+    }
+    return c.JSON(fiber.Map{"message": "Invitación revocada exitosamente"})
+}
+
+// usarInvitacion gasta un uso del código si sigue vigente y le corresponde al email. Se hace en
+// una sola operación para que dos registros al mismo tiempo no pasen del máximo de usos
+func usarInvitacion(ctx context.Context, codigo, email string) (models.Invitacion, error) {
+    var inv models.Invitacion
+    filter := bson.M{
+        "codigo_hash": utils.HashCodigoInvitacion(codigo),
+        "revocada":    false,
+        "$expr":       bson.M{"$lt": bson.A{"$usos", "$usos_max"}},
+        "$and": bson.A{
+            bson.M{"$or": bson.A{bson.M{"expira_en": nil}, bson.M{"expira_en": bson.M{"$gt": time.Now().UTC()}}}},
+            bson.M{"$or": bson.A{bson.M{"email": nil}, bson.M{"email": email}}},
+        },
+    }
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+    err := getCollectionInvitaciones().FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"usos": 1}}, opts).Decode(&inv)
+    return inv, err
+}
+
+// devolverInvitacion regresa el uso de una invitación cuando el registro no se pudo completar
+func devolverInvitacion(ctx context.Context, invID primitive.ObjectID) error {
+    _, err := getCollectionInvitaciones().UpdateOne(ctx, bson.M{"_id": invID, "usos": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"usos": -1}})
+    return err
+}
+
+// crearIndicesInvitaciones crea el índice único del hash del código
+func crearIndicesInvitaciones(ctx context.Context) error {
+    _, err := getCollectionInvitaciones().Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "codigo_hash", Value: 1}},
+        Options: options.Index().SetName("codigo_hash_unico").SetUnique(true),
+    })
+    return err
+}
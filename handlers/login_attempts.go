@@ -0,0 +1,151 @@
+// handlers/login_attempts.go
+package handlers
+
+import (
+    "context"
+    "strconv"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// getCollectionLoginAttempts devuelve la colección "login_attempts"
+func getCollectionLoginAttempts() *mongo.Collection {
+    return config.ClientMongo.Database(config.DBName).Collection("login_attempts")
+}
+
+// claveEmail y claveIP son los IDs con los que se cuentan los fallos
+func claveEmail(email string) string {
+    return "email:" + normalizarEmail(email)
+}
+
+func claveIP(ip string) string {
+    return "ip:" + ip
+}
+
+// tiempoBloqueo regresa cuanto falta para poder volver a intentar, 0 si no hay bloqueo
+func tiempoBloqueo(ctx context.Context, claves ...string) (time.Duration, error) {
+    cursor, err := getCollectionLoginAttempts().Find(ctx, bson.M{"_id": bson.M{"$in": claves}})
+    if err != nil {
+        return 0, err
+    }
+    defer cursor.Close(ctx)
+
+    var intentos []models.LoginAttempt
+    if err := cursor.All(ctx, &intentos); err != nil {
+        return 0, err
+    }
+
+    var espera time.Duration
+    for _, intento := range intentos {
+        if intento.BloqueadoHasta != nil {
+            if restante := time.Until(*intento.BloqueadoHasta); restante > espera {
+                espera = restante
+            }
+        }
+    }
+    return espera, nil
+}
+
+// actualizacionFallo suma un fallo a la clave y calcula en la misma operación hasta cuando queda
+// bloqueada: la espera crece de forma exponencial y al llegar a maxFallos se bloquea por
+// config.LoginBloqueo. bloqueado_hasta y expira_en solo crecen ($max), así un fallo que llega tarde
+// con una cuenta vieja no acorta un bloqueo más largo
+func actualizacionFallo(ahora time.Time, maxFallos int) mongo.Pipeline {
+    ms := func(d time.Duration) int64 { return int64(d / time.Millisecond) }
+    espera := bson.M{"$cond": bson.A{
+        bson.M{"$gte": bson.A{"$fallos", maxFallos}},
+        ms(config.LoginBloqueo),
+        bson.M{"$min": bson.A{ms(config.LoginBackoffMax), bson.M{"$multiply": bson.A{ms(config.LoginBackoffBase), bson.M{"$pow": bson.A{2, bson.M{"$subtract": bson.A{"$fallos", 1}}}}}}}},
+    }}
+    hasta := bson.M{"$add": bson.A{ahora, espera}}
+    return mongo.Pipeline{
+        {{Key: "$set", Value: bson.M{"fallos": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$fallos", 0}}, 1}}, "ultimo_fallo": ahora}}},
+        // el documento no debe borrarse por TTL mientras siga bloqueado
+        {{Key: "$set", Value: bson.M{
+            "bloqueado_hasta": bson.M{"$max": bson.A{"$bloqueado_hasta", hasta}},
+            "expira_en":       bson.M{"$max": bson.A{"$expira_en", bson.M{"$add": bson.A{hasta, ms(config.LoginVentana)}}}},
+        }}},
+    }
+}
+
+// registrarFallo suma un fallo a la clave después de un intento incorrecto
+func registrarFallo(ctx context.Context, clave string, maxFallos int) error {
+    opts := options.Update().SetUpsert(true)
+    _, err := getCollectionLoginAttempts().UpdateOne(ctx, bson.M{"_id": clave}, actualizacionFallo(time.Now(), maxFallos), opts)
+    return err
+}
+
+// reservarIntento cuenta el intento como fallo antes de revisar la contraseña o el código, solo si
+// la clave no está bloqueada. Es una sola operación atómica: de varias peticiones al mismo tiempo
+// solo una pasa y las demás ven la espera que dejó, así una ráfaga no se salta la espera. Regresa
+// la espera que queda si la clave está bloqueada; si el intento sale bien se llama limpiarFallos
+func reservarIntento(ctx context.Context, clave string, maxFallos int) (time.Duration, error) {
+    ahora := time.Now()
+    filter := bson.M{"_id": clave, "bloqueado_hasta": bson.M{"$not": bson.M{"$gt": ahora}}}
+    opts := options.Update().SetUpsert(true)
+    _, err := getCollectionLoginAttempts().UpdateOne(ctx, filter, actualizacionFallo(ahora, maxFallos), opts)
+    // Si la clave está bloqueada el filtro no encuentra el documento y el upsert choca con su _id
+    if mongo.IsDuplicateKeyError(err) {
+        espera, err := tiempoBloqueo(ctx, clave)
+        if err == nil && espera <= 0 {
+            // El bloqueo terminó entre las dos consultas, la espera mínima hace que vuelva a intentar
+            espera = time.Second
+        }
+        return espera, err
+    }
+    return 0, err
+}
+
+// limpiarFallos borra el contador de una clave después de un login correcto
+func limpiarFallos(ctx context.Context, clave string) error {
+    _, err := getCollectionLoginAttempts().DeleteOne(ctx, bson.M{"_id": clave})
+    return err
+}
+
+// responderBloqueo contesta 429 con el header Retry-After en segundos
+func responderBloqueo(c *fiber.Ctx, espera time.Duration) error {
+    segundos := int(espera / time.Second)
+    if espera%time.Second != 0 {
+        segundos++
+    }
+    c.Set(fiber.HeaderRetryAfter, strconv.Itoa(segundos))
+    return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Demasiados intentos fallidos, intenta más tarde"})
+}
+
+// UnlockUser quita el bloqueo por intentos fallidos de una cuenta (solo administradores)
+func UnlockUser(c *fiber.Ctx) error {
+    idParam := c.Params("id")
+    objectID, err := primitive.ObjectIDFromHex(idParam)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    var user models.User
+    if err := getCollectionUsers().FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&user); err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if err := limpiarFallos(ctx, claveEmail(user.Email)); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo desbloquear la cuenta"})
+    }
+    return c.JSON(fiber.Map{"message": "Cuenta desbloqueada exitosamente"})
+}
+
+// crearIndicesLoginAttempts crea el índice TTL que borra los contadores viejos
+func crearIndicesLoginAttempts(ctx context.Context) error {
+    _, err := getCollectionLoginAttempts().Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "expira_en", Value: 1}},
+        Options: options.Index().SetExpireAfterSeconds(0),
+    })
+    return err
+}
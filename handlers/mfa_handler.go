@@ -0,0 +1,281 @@
+// handlers/mfa_handler.go
+package handlers
+
+import (
+    "context"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "golang.org/x/crypto/bcrypt"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// cantidadCodigosRecuperacion es cuantos códigos de un solo uso se generan al activar 2FA
+const cantidadCodigosRecuperacion = 10
+
+// claveMFA es la clave con la que se cuentan los códigos 2FA incorrectos de un usuario
+func claveMFA(userID string) string {
+    return "mfa:" + userID
+}
+
+// verificarSegundoFactor acepta un código TOTP o un código de recuperación. El TOTP no se puede
+// repetir (se guarda el último paso usado) y el código de recuperación se borra al usarlo
+func verificarSegundoFactor(ctx context.Context, user models.User, codigo, codigoRecuperacion string) (bool, error) {
+    col := getCollectionUsers()
+
+    if codigo != "" {
+        paso, ok := utils.VerificarTOTP(user.TOTPSecreto, codigo, time.Now())
+        if !ok {
+            return false, nil
+        }
+        filter := bson.M{"_id": user.ID, "totp_ultimo_paso": bson.M{"$not": bson.M{"$gte": paso}}}
+        result, err := col.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_ultimo_paso": paso}})
+        if err != nil {
+            return false, err
+        }
+        return result.ModifiedCount == 1, nil
+    }
+
+    if codigoRecuperacion != "" {
+        hash := utils.HashCodigoRecuperacion(codigoRecuperacion)
+        filter := bson.M{"_id": user.ID, "codigos_recuperacion": hash}
+        result, err := col.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"codigos_recuperacion": hash}})
+        if err != nil {
+            return false, err
+        }
+        return result.ModifiedCount == 1, nil
+    }
+
+    return false, nil
+}
+
+// SetupTOTP genera un secreto nuevo y el URI otpauth, queda pendiente hasta confirmarlo con un código
+func SetupTOTP(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if user.TOTPActivo {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La verificación en dos pasos ya está activa"})
+    }
+
+    secreto, err := utils.GenerarSecretoTOTP()
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar secreto"})
+    }
+    if _, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"totp_pendiente": secreto}}); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo guardar el secreto"})
+    }
+
+    return c.JSON(fiber.Map{
+        "secreto":     secreto,
+        "otpauth_uri": utils.URITOTP(config.TOTPEmisor, user.Email, secreto),
+    })
+}
+
+// ConfirmTOTP activa 2FA si el código corresponde al secreto pendiente y entrega los códigos
+// de recuperación, es la única vez que se muestran en claro
+func ConfirmTOTP(c *fiber.Ctx) error {
+    type Request struct {
+        Codigo string `json:"codigo"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if user.TOTPPendiente == "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Primero hay que generar el secreto"})
+    }
+    paso, ok := utils.VerificarTOTP(user.TOTPPendiente, body.Codigo, time.Now())
+    if !ok {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Código incorrecto"})
+    }
+
+    codigos, hashes, err := utils.GenerarCodigosRecuperacion(cantidadCodigosRecuperacion)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar códigos de recuperación"})
+    }
+
+    update := bson.M{
+        "$set": bson.M{
+            "totp_activo":          true,
+            "totp_secreto":         user.TOTPPendiente,
+            "totp_ultimo_paso":     paso,
+            "codigos_recuperacion": hashes,
+        },
+        "$unset": bson.M{"totp_pendiente": ""},
+    }
+    if _, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo activar la verificación en dos pasos"})
+    }
+
+    return c.JSON(fiber.Map{
+        "message":              "Verificación en dos pasos activada",
+        "codigos_recuperacion": codigos,
+    })
+}
+
+// DisableTOTP desactiva 2FA, pide la contraseña y un código (TOTP o de recuperación)
+func DisableTOTP(c *fiber.Ctx) error {
+    type Request struct {
+        Password           string `json:"password"`
+        Codigo             string `json:"codigo"`
+        CodigoRecuperacion string `json:"codigo_recuperacion"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if !user.TOTPActivo {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La verificación en dos pasos no está activa"})
+    }
+    if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)) != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Contraseña incorrecta"})
+    }
+    ok, err := verificarSegundoFactor(ctx, user, body.Codigo, body.CodigoRecuperacion)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar código"})
+    }
+    if !ok {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Código incorrecto"})
+    }
+
+    update := bson.M{
+        "$set":   bson.M{"totp_activo": false},
+        "$unset": bson.M{"totp_secreto": "", "totp_pendiente": "", "totp_ultimo_paso": "", "codigos_recuperacion": ""},
+    }
+    if _, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo desactivar la verificación en dos pasos"})
+    }
+    return c.JSON(fiber.Map{"message": "Verificación en dos pasos desactivada"})
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación, los anteriores dejan de servir
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+    type Request struct {
+        Codigo string `json:"codigo"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if !user.TOTPActivo {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La verificación en dos pasos no está activa"})
+    }
+    ok, err := verificarSegundoFactor(ctx, user, body.Codigo, "")
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar código"})
+    }
+    if !ok {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Código incorrecto"})
+    }
+
+    codigos, hashes, err := utils.GenerarCodigosRecuperacion(cantidadCodigosRecuperacion)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar códigos de recuperación"})
+    }
+    if _, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"codigos_recuperacion": hashes}}); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudieron guardar los códigos"})
+    }
+    return c.JSON(fiber.Map{"codigos_recuperacion": codigos})
+}
+
+// LoginTOTP es el segundo paso del login con 2FA: cambia el token "mfa pendiente" más un
+// código válido por el par access/refresh. El token pendiente solo se puede usar una vez
+func LoginTOTP(c *fiber.Ctx) error {
+    type Request struct {
+        MFAToken           string `json:"mfa_token"`
+        Codigo             string `json:"codigo"`
+        CodigoRecuperacion string `json:"codigo_recuperacion"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    claims, err := utils.ValidarTokenTipo(body.MFAToken, utils.TipoMFA)
+    if err != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token inválido: " + err.Error()})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    revocado, err := utils.TokenRevocado(ctx, claims.ID)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al validar token"})
+    }
+    if revocado {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token ya utilizado"})
+    }
+
+    clave := claveMFA(claims.UserID)
+    espera, err := reservarIntento(ctx, clave, config.LoginMaxFallos)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+    if espera > 0 {
+        return responderBloqueo(c, espera)
+    }
+
+    user, err := buscarUsuarioPorID(ctx, claims.UserID)
+    if err != nil || !user.TOTPActivo || user.TokenVersion != claims.Version {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token inválido"})
+    }
+    if inactiva, err := responderEstadoCuenta(c, user); inactiva {
+        return err
+    }
+
+    ok, err := verificarSegundoFactor(ctx, user, body.Codigo, body.CodigoRecuperacion)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar código"})
+    }
+    if !ok {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Código incorrecto"})
+    }
+
+    if err := limpiarFallos(ctx, clave); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+    if err := utils.RevocarToken(ctx, claims); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+    }
+
+    tokens, err := emitirTokens(ctx, user, primitive.NewObjectID())
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+    }
+    return c.JSON(tokens)
+}
@@ -0,0 +1,67 @@
+// handlers/migrations.go
+package handlers
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// EjecutarMigraciones completa los documentos creados antes de que existieran algunos campos,
+// todas son idempotentes asi que se pueden correr en cada arranque
+func EjecutarMigraciones() {
+    ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+    defer cancel()
+
+    // Usuarios registrados antes de que existieran los roles
+    result, err := getCollectionUsers().UpdateMany(ctx, bson.M{"rol": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"rol": models.RolUsuario}})
+    if err != nil {
+        log.Fatal("Error al migrar roles de usuarios: ", err)
+    }
+    if result.ModifiedCount > 0 {
+        log.Println("Migración: se asignó el rol", models.RolUsuario, "a", result.ModifiedCount, "usuarios")
+    }
+
+    // Emails guardados antes de normalizarlos
+    if err := migrarEmails(ctx); err != nil {
+        log.Fatal("Error al normalizar emails: ", err)
+    }
+
+    // Las cuentas creadas antes de la verificación de email se consideran verificadas
+    result, err = getCollectionUsers().UpdateMany(ctx, bson.M{"email_verificado": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"email_verificado": true}})
+    if err != nil {
+        log.Fatal("Error al migrar verificación de email: ", err)
+    }
+    if result.ModifiedCount > 0 {
+        log.Println("Migración: se marcaron", result.ModifiedCount, "usuarios existentes como verificados")
+    }
+
+    // Usuarios registrados antes de que existiera el estado de la cuenta
+    result, err = getCollectionUsers().UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"status": models.EstadoActivo}})
+    if err != nil {
+        log.Fatal("Error al migrar estado de usuarios: ", err)
+    }
+    if result.ModifiedCount > 0 {
+        log.Println("Migración: se marcaron", result.ModifiedCount, "usuarios existentes como activos")
+    }
+
+    // Tasks creadas antes de que existieran el estado y la prioridad
+    result, err = getCollectionTasks().UpdateMany(ctx, bson.M{"estado": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"estado": models.EstadoTaskPendiente}})
+    if err != nil {
+        log.Fatal("Error al migrar estado de tasks: ", err)
+    }
+    if result.ModifiedCount > 0 {
+        log.Println("Migración: se marcaron", result.ModifiedCount, "tasks existentes como pendientes")
+    }
+    result, err = getCollectionTasks().UpdateMany(ctx, bson.M{"prioridad": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"prioridad": models.PrioridadDefault}})
+    if err != nil {
+        log.Fatal("Error al migrar prioridad de tasks: ", err)
+    }
+    if result.ModifiedCount > 0 {
+        log.Println("Migración: se asignó la prioridad", models.PrioridadDefault, "a", result.ModifiedCount, "tasks")
+    }
+}
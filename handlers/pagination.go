@@ -0,0 +1,70 @@
+// handlers/pagination.go
+package handlers
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// leerPagina lee limit, cursor y sort del query string. campos es la lista blanca de ordenes
+// y ordenDefault el que se usa si no viene sort. Los errores se agregan por parámetro
+func leerPagina(c *fiber.Ctx, campos map[string]utils.CampoOrden, ordenDefault string, errores erroresCampo) utils.Pagina {
+    limite := c.QueryInt("limit", utils.LimitePaginaDefault)
+    if limite < 1 || limite > utils.LimitePaginaMax {
+        errores.agregar("limit", fmt.Sprintf("Debe estar entre 1 y %d", utils.LimitePaginaMax))
+    }
+    orden := c.Query("sort", ordenDefault)
+
+    p, err := utils.NuevaPagina(limite, orden, c.Query("cursor"), campos)
+    if err == utils.ErrCursorInvalido {
+        errores.agregar("cursor", "Cursor inválido o de otro orden")
+    } else if err != nil {
+        errores.agregar("sort", "Orden no permitido")
+    }
+    return p
+}
+
+// parsearFechaFiltro lee una fecha de filtro en formato YYYY-MM-DD o RFC 3339. Si fin es true y
+// solo viene el día, regresa el inicio del día siguiente para que el rango incluya todo ese día
+func parsearFechaFiltro(valor string, fin bool) (time.Time, bool) {
+    if fecha, err := time.Parse(time.RFC3339, valor); err == nil {
+        return fecha, true
+    }
+    fecha, err := time.Parse("2006-01-02", valor)
+    if err != nil {
+        return fecha, false
+    }
+    if fin {
+        fecha = fecha.AddDate(0, 0, 1)
+    }
+    return fecha, true
+}
+
+// enlacePagina arma la URL de la petición actual cambiando solo el cursor
+func enlacePagina(c *fiber.Ctx, cursor string) interface{} {
+    if cursor == "" {
+        return nil
+    }
+    args := fiber.AcquireArgs()
+    defer fiber.ReleaseArgs(args)
+    c.Context().QueryArgs().CopyTo(args)
+    args.Set("cursor", cursor)
+    return c.Path() + "?" + args.String()
+}
+
+// respuestaPagina es el formato común de los listados paginados, datos son los documentos de
+// res ya convertidos a su respuesta pública
+func respuestaPagina[T, R any](c *fiber.Ctx, datos []R, res utils.Resultado[T], p utils.Pagina) fiber.Map {
+    return fiber.Map{
+        "data":  datos,
+        "total": res.Total,
+        "limit": p.Limite,
+        "sort":  p.Orden,
+        "next":  enlacePagina(c, res.Siguiente),
+        "prev":  enlacePagina(c, res.Anterior),
+    }
+}
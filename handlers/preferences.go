@@ -0,0 +1,104 @@
+// handlers/preferences.go
+package handlers
+
+import (
+    "context"
+    "strings"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+    "golang.org/x/text/language"
+
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// PreferenciasRequest son las preferencias que se pueden mandar al registrarse o al editar la
+// cuenta, las que no vienen no se cambian
+type PreferenciasRequest struct {
+    ZonaHoraria  *string `json:"zona_horaria"`
+    Idioma       *string `json:"idioma"`
+    InicioSemana *string `json:"inicio_semana"`
+}
+
+// validar revisa cada preferencia y regresa las válidas ya normalizadas, las que no vienen quedan vacías
+func (r PreferenciasRequest) validar(errores erroresCampo) models.Preferencias {
+    var p models.Preferencias
+    if r.ZonaHoraria != nil {
+        if loc, ok := cargarZona(*r.ZonaHoraria); ok {
+            p.ZonaHoraria = loc.String()
+        } else {
+            errores.agregar("zona_horaria", "Zona horaria inválida, usa un nombre IANA como America/Mexico_City")
+        }
+    }
+    if r.Idioma != nil {
+        if tag, err := language.Parse(strings.TrimSpace(*r.Idioma)); err == nil {
+            p.Idioma = tag.String()
+        } else {
+            errores.agregar("idioma", "Idioma inválido, usa una etiqueta como es-MX")
+        }
+    }
+    if r.InicioSemana != nil {
+        dia := strings.ToLower(strings.TrimSpace(*r.InicioSemana))
+        if _, ok := models.DiasInicioSemana[dia]; ok {
+            p.InicioSemana = dia
+        } else {
+            errores.agregar("inicio_semana", "Debe ser lunes, domingo o sabado")
+        }
+    }
+    return p
+}
+
+// setPreferencias agrega al $set las preferencias que no están vacías
+func setPreferencias(set bson.M, p models.Preferencias) {
+    if p.ZonaHoraria != "" {
+        set["preferencias.zona_horaria"] = p.ZonaHoraria
+    }
+    if p.Idioma != "" {
+        set["preferencias.idioma"] = p.Idioma
+    }
+    if p.InicioSemana != "" {
+        set["preferencias.inicio_semana"] = p.InicioSemana
+    }
+}
+
+// cargarZona carga una zona IANA. "" y "Local" no se aceptan porque dependen del servidor
+func cargarZona(nombre string) (*time.Location, bool) {
+    nombre = strings.TrimSpace(nombre)
+    if nombre == "" || nombre == "Local" {
+        return nil, false
+    }
+    loc, err := time.LoadLocation(nombre)
+    return loc, err == nil
+}
+
+// preferenciasUsuario carga las preferencias del usuario, con los valores por defecto si no tiene
+func preferenciasUsuario(ctx context.Context, userIDHex string) models.Preferencias {
+    var user models.User
+    objectID, err := primitive.ObjectIDFromHex(userIDHex)
+    if err == nil {
+        opts := options.FindOne().SetProjection(bson.M{"preferencias": 1})
+        getCollectionUsers().FindOne(ctx, bson.M{"_id": objectID}, opts).Decode(&user)
+    }
+    return user.Preferencias.ConDefaults()
+}
+
+// zonaRespuesta lee ?tz para mostrar las fechas de la respuesta en otra zona: "user" usa la zona
+// del usuario y cualquier otro valor es un nombre IANA. Sin ?tz las fechas van en UTC
+func zonaRespuesta(c *fiber.Ctx, prefs models.Preferencias, errores erroresCampo) *time.Location {
+    tz := c.Query("tz")
+    switch tz {
+    case "":
+        return time.UTC
+    case "user":
+        return prefs.Ubicacion()
+    }
+    loc, ok := cargarZona(tz)
+    if !ok {
+        errores.agregar("tz", "Zona horaria inválida, usa user o un nombre IANA")
+        return time.UTC
+    }
+    return loc
+}
@@ -0,0 +1,173 @@
+// handlers/recovery_handler.go
+package handlers
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/binary"
+    "strconv"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+    "golang.org/x/crypto/bcrypt"
+
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// MaxIntentosRecuperacion es cuantas respuestas incorrectas se permiten antes de bloquear la recuperación
+const MaxIntentosRecuperacion = 5
+
+// BloqueoRecuperacion es el tiempo que la recuperación queda bloqueada al agotar los intentos
+const BloqueoRecuperacion = 15 * time.Minute
+
+// preguntasSenuelo se regresan para los emails sin cuenta, así la respuesta no dice si el email está registrado
+var preguntasSenuelo = []string{
+    "¿Cuál es el nombre de tu primera mascota?",
+    "¿En qué ciudad naciste?",
+    "¿Cuál es el segundo nombre de tu madre?",
+    "¿Cómo se llamaba tu escuela primaria?",
+    "¿Cuál fue tu primer trabajo?",
+}
+
+// preguntaSenuelo elige siempre la misma pregunta para el mismo email, así repetir la consulta no la delata
+func preguntaSenuelo(email string) string {
+    sum := sha256.Sum256([]byte(normalizarEmail(email)))
+    return preguntasSenuelo[binary.BigEndian.Uint32(sum[:4])%uint32(len(preguntasSenuelo))]
+}
+
+// recuperacionBloqueada responde 429 si la cuenta tiene la recuperación bloqueada
+func recuperacionBloqueada(c *fiber.Ctx, user models.User) (bool, error) {
+    if user.RecuperacionBloqueada == nil || time.Now().After(*user.RecuperacionBloqueada) {
+        return false, nil
+    }
+    restante := int(time.Until(*user.RecuperacionBloqueada).Seconds()) + 1
+    c.Set(fiber.HeaderRetryAfter, strconv.Itoa(restante))
+    return true, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Demasiados intentos, intenta más tarde"})
+}
+
+// GetRecoveryQuestion es el primer paso de la recuperación, regresa la pregunta secreta del email.
+// Si el email no tiene cuenta se regresa una pregunta señuelo con la misma forma de respuesta
+func GetRecoveryQuestion(c *fiber.Ctx) error {
+    type Request struct {
+        Email string `json:"email"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil || body.Email == "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := buscarUsuarioPorEmail(ctx, body.Email)
+    if err != nil || user.PreguntaSecreta == "" {
+        return c.JSON(fiber.Map{"pregunta_secreta": preguntaSenuelo(body.Email)})
+    }
+    if bloqueada, err := recuperacionBloqueada(c, user); bloqueada {
+        return err
+    }
+
+    return c.JSON(fiber.Map{"pregunta_secreta": user.PreguntaSecreta})
+}
+
+// ResetPassword es el segundo paso, verifica la respuesta secreta y cambia la contraseña.
+// Al terminar se cierran todas las sesiones abiertas del usuario
+func ResetPassword(c *fiber.Ctx) error {
+    type Request struct {
+        Email            string `json:"email"`
+        RespuestaSecreta string `json:"respuesta_secreta"`
+        NuevoPassword    string `json:"nuevo_password"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil || body.Email == "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    if err := utils.ValidarPassword(body.NuevoPassword); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+    }
+
+    col := getCollectionUsers()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    // Un email sin cuenta responde igual que una respuesta incorrecta
+    user, err := buscarUsuarioPorEmail(ctx, body.Email)
+    if err != nil || user.PreguntaSecreta == "" {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Respuesta secreta incorrecta"})
+    }
+    if bloqueada, err := recuperacionBloqueada(c, user); bloqueada {
+        return err
+    }
+
+    // El intento se cuenta con un solo $inc atómico antes de comparar la respuesta y la decisión se
+    // toma con el valor que regresa, así peticiones en paralelo no ven todas la misma cuenta y nunca
+    // se comparan más de MaxIntentosRecuperacion respuestas por bloqueo
+    var contado models.User
+    filter := bson.M{"_id": user.ID, "recuperacion_bloqueada": bson.M{"$not": bson.M{"$gt": time.Now()}}}
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+    err = col.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"intentos_recuperacion": 1}}, opts).Decode(&contado)
+    if err == mongo.ErrNoDocuments {
+        // Otra petición bloqueó la recuperación después de leer al usuario
+        return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Demasiados intentos, intenta más tarde"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al registrar intento"})
+    }
+    bloquear := func() error {
+        update := bson.M{"$set": bson.M{"intentos_recuperacion": 0, "recuperacion_bloqueada": time.Now().Add(BloqueoRecuperacion)}}
+        _, err := col.UpdateOne(ctx, bson.M{"_id": user.ID}, update)
+        return err
+    }
+    if contado.IntentosRecuperacion > MaxIntentosRecuperacion {
+        if err := bloquear(); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al registrar intento"})
+        }
+        return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Demasiados intentos, intenta más tarde"})
+    }
+
+    if !utils.CompararRespuesta(user.RespuestaSecreta, body.RespuestaSecreta) {
+        // Con el último intento fallido se bloquea la recuperación un rato
+        if contado.IntentosRecuperacion >= MaxIntentosRecuperacion {
+            if err := bloquear(); err != nil {
+                return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al registrar intento"})
+            }
+        }
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Respuesta secreta incorrecta"})
+    }
+
+    // Una cuenta bloqueada suele estar comprometida y quien la tomó puede saber la respuesta secreta,
+    // así que no se le cambia la contraseña; solo un admin la reactiva (ReactivateUser)
+    if user.Estado == models.EstadoBloqueado {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": models.MensajeEstado(user.Estado)})
+    }
+
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NuevoPassword), bcrypt.DefaultCost)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al encriptar contraseña"})
+    }
+
+    set := bson.M{"password": string(hashedPassword), "intentos_recuperacion": 0}
+    // Si la respuesta venía de una cuenta vieja en texto plano se aprovecha para hashearla
+    if !utils.EsHashBcrypt(user.RespuestaSecreta) {
+        hashedRespuesta, err := utils.HashRespuesta(body.RespuestaSecreta)
+        if err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al encriptar respuesta secreta"})
+        }
+        set["respuesta_secreta"] = hashedRespuesta
+    }
+
+    update := bson.M{"$set": set, "$unset": bson.M{"recuperacion_bloqueada": ""}}
+    if _, err := col.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la contraseña"})
+    }
+
+    if err := cerrarSesiones(ctx, user.ID); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudieron cerrar las sesiones"})
+    }
+
+    return c.JSON(fiber.Map{"message": "Contraseña actualizada exitosamente"})
+}
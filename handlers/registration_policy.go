@@ -0,0 +1,175 @@
+// handlers/registration_policy.go
+package handlers
+
+import (
+    "strings"
+    "time"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// RegistroRequest son los datos para crear una cuenta
+type RegistroRequest struct {
+    Nombre           string `json:"nombre"`
+    Apellidos        string `json:"apellidos"`
+    Email            string `json:"email"`
+    Password         string `json:"password"`
+    FechaNacimiento  string `json:"fecha_nacimiento"` // ISO string: "2023-01-02"
+    PreguntaSecreta  string `json:"pregunta_secreta"`
+    RespuestaSecreta string `json:"respuesta_secreta"`
+    CodigoInvitacion string `json:"codigo_invitacion"`
+    PreferenciasRequest
+
+    fechaNacimiento time.Time // se llena al validar
+}
+
+// reglaRegistro revisa una parte de la solicitud y agrega los errores de sus campos
+type reglaRegistro func(r *RegistroRequest, errores erroresCampo)
+
+// reglasRegistro son las reglas que tiene que pasar un registro, en orden. Cada campo se queda
+// con el primer error que encuentre, así "requerido" gana sobre "formato inválido"
+var reglasRegistro = []reglaRegistro{
+    reglaCamposRequeridos,
+    reglaEmail,
+    reglaDominioEmail,
+    reglaPassword,
+    reglaNombre,
+    reglaFechaNacimiento,
+    reglaInvitacion,
+}
+
+// validar normaliza la solicitud y aplica todas las reglas, regresa los errores de todos los campos juntos
+func (r *RegistroRequest) validar() erroresCampo {
+    r.Nombre = strings.TrimSpace(r.Nombre)
+    r.Apellidos = strings.TrimSpace(r.Apellidos)
+    r.Email = normalizarEmail(r.Email)
+    r.FechaNacimiento = strings.TrimSpace(r.FechaNacimiento)
+    r.PreguntaSecreta = strings.TrimSpace(r.PreguntaSecreta)
+    r.CodigoInvitacion = strings.TrimSpace(r.CodigoInvitacion)
+
+    errores := erroresCampo{}
+    for _, regla := range reglasRegistro {
+        regla(r, errores)
+    }
+    return errores
+}
+
+// reglaCamposRequeridos revisa email y password, que siempre se piden, y los campos de config.RegistroCamposRequeridos
+func reglaCamposRequeridos(r *RegistroRequest, errores erroresCampo) {
+    valores := map[string]string{
+        "nombre":            r.Nombre,
+        "apellidos":         r.Apellidos,
+        "email":             r.Email,
+        "password":          r.Password,
+        "fecha_nacimiento":  r.FechaNacimiento,
+        "pregunta_secreta":  r.PreguntaSecreta,
+        "respuesta_secreta": strings.TrimSpace(r.RespuestaSecreta),
+    }
+    requeridos := append([]string{"email", "password"}, config.RegistroCamposRequeridos...)
+    for _, campo := range requeridos {
+        if valor, existe := valores[campo]; existe && valor == "" {
+            errores.agregar(campo, "Este campo es obligatorio")
+        }
+    }
+}
+
+// reglaEmail revisa el formato del email
+func reglaEmail(r *RegistroRequest, errores erroresCampo) {
+    if r.Email != "" && !emailValido(r.Email) {
+        errores.agregar("email", "Email inválido")
+    }
+}
+
+// reglaDominioEmail aplica las listas de dominios permitidos y bloqueados
+func reglaDominioEmail(r *RegistroRequest, errores erroresCampo) {
+    if !dominioEmailPermitido(r.Email) {
+        errores.agregar("email", "El dominio del email no está permitido para registrarse")
+    }
+}
+
+// dominioEmailPermitido revisa el dominio del email contra las listas de registro, un subdominio
+// cuenta como su dominio ("mail.empresa.com" entra en "empresa.com"). También se usa al cambiar el email
+func dominioEmailPermitido(email string) bool {
+    arroba := strings.LastIndex(email, "@")
+    if arroba < 0 {
+        return true
+    }
+    dominio := email[arroba+1:]
+    if len(config.RegistroDominiosPermitidos) > 0 && !dominioEnLista(dominio, config.RegistroDominiosPermitidos) {
+        return false
+    }
+    return !dominioEnLista(dominio, config.RegistroDominiosBloqueados)
+}
+
+// dominioEnLista indica si el dominio o alguno de sus dominios padre está en la lista
+func dominioEnLista(dominio string, lista []string) bool {
+    for _, d := range lista {
+        if dominio == d || strings.HasSuffix(dominio, "."+d) {
+            return true
+        }
+    }
+    return false
+}
+
+// reglaPassword aplica la misma política que al cambiar la contraseña
+func reglaPassword(r *RegistroRequest, errores erroresCampo) {
+    if r.Password == "" {
+        return
+    }
+    if err := utils.ValidarPassword(r.Password); err != nil {
+        errores.agregar("password", err.Error())
+    }
+}
+
+// reglaNombre limita el largo de nombre y apellidos
+func reglaNombre(r *RegistroRequest, errores erroresCampo) {
+    if len([]rune(r.Nombre)) > longitudMaximaNombre {
+        errores.agregar("nombre", "El nombre es demasiado largo")
+    }
+    if len([]rune(r.Apellidos)) > longitudMaximaNombre {
+        errores.agregar("apellidos", "Los apellidos son demasiado largos")
+    }
+}
+
+// reglaFechaNacimiento revisa la fecha y la edad mínima. Si hay edad mínima la fecha es obligatoria
+func reglaFechaNacimiento(r *RegistroRequest, errores erroresCampo) {
+    if r.FechaNacimiento == "" {
+        if config.RegistroEdadMinima > 0 {
+            errores.agregar("fecha_nacimiento", "La fecha de nacimiento es obligatoria para verificar la edad")
+        }
+        return
+    }
+    fecha, problema := parsearFechaNacimiento(r.FechaNacimiento)
+    if problema != "" {
+        errores.agregar("fecha_nacimiento", problema)
+        return
+    }
+    if !edadSuficiente(fecha) {
+        errores.agregar("fecha_nacimiento", "No cumples la edad mínima para registrarte")
+        return
+    }
+    r.fechaNacimiento = fecha
+}
+
+// edadSuficiente indica si la fecha de nacimiento cumple config.RegistroEdadMinima
+func edadSuficiente(nacimiento time.Time) bool {
+    return config.RegistroEdadMinima <= 0 || edad(nacimiento, time.Now()) >= config.RegistroEdadMinima
+}
+
+// edad regresa los años cumplidos en la fecha hoy
+func edad(nacimiento, hoy time.Time) int {
+    anios := hoy.Year() - nacimiento.Year()
+    if hoy.Month() < nacimiento.Month() || (hoy.Month() == nacimiento.Month() && hoy.Day() < nacimiento.Day()) {
+        anios--
+    }
+    return anios
+}
+
+// reglaInvitacion pide el código cuando el registro es solo por invitación. Que el código sea
+// válido se revisa al usarlo, porque eso gasta uno de sus usos
+func reglaInvitacion(r *RegistroRequest, errores erroresCampo) {
+    if config.RegistroSoloInvitacion && r.CodigoInvitacion == "" {
+        errores.agregar("codigo_invitacion", "El registro es solo por invitación")
+    }
+}
@@ -3,15 +3,19 @@ package handlers
 
 import (
     "context"
+    "regexp"
+    "strings"
     "time"
 
     "github.com/gofiber/fiber/v2"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/bson/primitive"
     "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
 
     "github.com/ImanolCE/api-rest-go/config"
     "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
 )
 
 // getCollectionTasks devuelve la colección "tasks"
@@ -19,15 +23,10 @@ func getCollectionTasks() *mongo.Collection {
     return config.ClientMongo.Database(config.DBName).Collection("tasks")
 }
 
-// CreateTask permite a un usuario autenticado crear una nueva task
+// CreateTask permite a un usuario autenticado crear una nueva task. Regresa 400 con los errores
+// de todos los campos si algo no es válido
 func CreateTask(c *fiber.Ctx) error {
-    type Request struct {
-        Titulo      string `json:"titulo"`
-        Descripcion string `json:"descripcion"`
-        FechaInicio string `json:"fecha_inicio"` // "2006-01-02T15:04:05Z07:00"
-        FechaFinal    string `json:"fecha_final"`
-    }
-    var body Request
+    var body TaskRequest
     if err := c.BodyParser(&body); err != nil {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
@@ -39,37 +38,61 @@ func CreateTask(c *fiber.Ctx) error {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
     }
 
-    // Parsear fechas
-    fechaInicio, err := time.Parse(time.RFC3339, body.FechaInicio)
-    if err != nil {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Fecha de inicio inválida"})
-    }
-    FechaFinal, err := time.Parse(time.RFC3339, body.FechaFinal)
+    errores := erroresCampo{}
+    extra, err := camposNoPermitidos(c.Body(), camposCreacionTask...)
     if err != nil {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "FechaFinal inválido"})
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
-
-    newTask := models.Task{
-        ID:           primitive.NewObjectID(),
-        Titulo:       body.Titulo,
-        Descripcion:  body.Descripcion,
-        FechaInicio:  fechaInicio,
-        FechaFinal:     FechaFinal,
-        UsuarioID:    userObjID,
+    for _, campo := range extra {
+        errores.agregar(campo, "El campo no se puede asignar")
     }
 
     col := getCollectionTasks()
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
+    // Las fechas que no traen offset son de la zona horaria del usuario
+    prefs := preferenciasUsuario(ctx, userIDHex)
+    loc := zonaRespuesta(c, prefs, errores)
+    newTask := models.Task{
+        ID:        primitive.NewObjectID(),
+        Estado:    models.EstadoTaskPendiente,
+        Prioridad: models.PrioridadDefault,
+        UsuarioID: userObjID,
+    }
+    _, erroresValidacion := body.validar(&newTask, prefs.Ubicacion(), true)
+    for campo, mensaje := range erroresValidacion {
+        errores.agregar(campo, mensaje)
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
     _, err = col.InsertOne(ctx, newTask)
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al crear task"})
     }
-    return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Task creada exitosamente"})
+    return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Task creada exitosamente", "task": models.NewTaskResponse(newTask).EnZona(loc)})
 }
 
-// GetTasks retorna las tasks del usuario autenticado
+// ordenesTasks son los campos por los que se puede ordenar GET /api/tasks, "creada" ordena por
+// fecha de creación que sale del _id
+var ordenesTasks = map[string]utils.CampoOrden{
+    "fecha_inicio": {Campo: "fecha_inicio", Tipo: bson.TypeDateTime},
+    "fecha_final":  {Campo: "fecha_final", Tipo: bson.TypeDateTime},
+    "titulo":       {Campo: "titulo", Tipo: bson.TypeString},
+    "creada":       {Campo: "_id"},
+}
+
+// GetTasks retorna las tasks del usuario autenticado, paginadas con limit, cursor y sort
+// (?sort=-fecha_final). Filtros:
+//   - inicio_desde, inicio_hasta, final_desde, final_hasta: rango sobre fecha_inicio y fecha_final
+//   - dia=YYYY-MM-DD o semana=YYYY-MM-DD: tasks que caen en ese día o semana
+//   - titulo: texto que contiene el título, sin distinguir mayúsculas
+//   - estado y prioridad: uno o varios valores separados por coma (?estado=pendiente,en_progreso)
+//
+// Las fechas sin offset se leen en la zona horaria del usuario. Una task que se repite aparece una
+// sola vez, GetAgenda es la que calcula sus ocurrencias
 func GetTasks(c *fiber.Ctx) error {
     userIDHex := c.Locals("userID").(string)
     userObjID, err := primitive.ObjectIDFromHex(userIDHex)
@@ -81,17 +104,171 @@ func GetTasks(c *fiber.Ctx) error {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    cursor, err := col.Find(ctx, bson.M{"usuario_id": userObjID})
+    prefs := preferenciasUsuario(ctx, userIDHex)
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, prefs, errores)
+    pagina := leerPagina(c, ordenesTasks, "fecha_inicio", errores)
+
+    var condiciones bson.A
+    if inicio, fin, ok := rangoTasks(c, prefs, errores); ok {
+        // Una task cae en el rango si empieza antes de que termine y termina después de que empieza
+        condiciones = append(condiciones, bson.M{"fecha_inicio": bson.M{"$lt": fin}}, bson.M{"fecha_final": bson.M{"$gte": inicio}})
+    }
+    for _, f := range []struct{ param, campo string }{
+        {"inicio_desde", "fecha_inicio"},
+        {"inicio_hasta", "fecha_inicio"},
+        {"final_desde", "fecha_final"},
+        {"final_hasta", "fecha_final"},
+    } {
+        if condicion, ok := filtroFechaTasks(c, f.param, f.campo, prefs.Ubicacion(), errores); ok {
+            condiciones = append(condiciones, condicion)
+        }
+    }
+    if titulo := strings.TrimSpace(c.Query("titulo")); titulo != "" {
+        if len([]rune(titulo)) > longitudMaximaTitulo {
+            errores.agregar("titulo", "El texto a buscar es demasiado largo")
+        }
+        condiciones = append(condiciones, bson.M{"titulo": primitive.Regex{Pattern: regexp.QuoteMeta(titulo), Options: "i"}})
+    }
+    if condicion, ok := filtroValoresTasks(c, "estado", models.EstadoTaskValido, mensajeEstadoTask, errores); ok {
+        condiciones = append(condiciones, condicion)
+    }
+    if condicion, ok := filtroValoresTasks(c, "prioridad", models.PrioridadValida, mensajePrioridad, errores); ok {
+        condiciones = append(condiciones, condicion)
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    filter := bson.M{"usuario_id": userObjID, "deleted_at": nil}
+    if len(condiciones) > 0 {
+        filter["$and"] = condiciones
+    }
+    res, err := utils.Paginar(ctx, col, filter, pagina, claveTask(pagina.Campo))
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al listar tasks"})
     }
-    defer cursor.Close(ctx)
+    return c.JSON(respuestaPagina(c, respuestasTasks(res.Items, loc), res, pagina))
+}
+
+// filtroFechaTasks lee un parámetro de rango (*_desde o *_hasta) y regresa la condición sobre campo.
+// Un *_hasta con solo el día incluye todo ese día
+func filtroFechaTasks(c *fiber.Ctx, param, campo string, loc *time.Location, errores erroresCampo) (bson.M, bool) {
+    valor := c.Query(param)
+    if valor == "" {
+        return nil, false
+    }
+    fecha, err := utils.ParsearFechaLocal(valor, loc)
+    if err != nil {
+        errores.agregar(param, "Fecha inválida (RFC 3339, YYYY-MM-DDTHH:MM o YYYY-MM-DD)")
+        return nil, false
+    }
+    if strings.HasSuffix(param, "_desde") {
+        return bson.M{campo: bson.M{"$gte": fecha}}, true
+    }
+    if len(valor) == len("2006-01-02") {
+        return bson.M{campo: bson.M{"$lt": fecha.In(loc).AddDate(0, 0, 1).UTC()}}, true
+    }
+    return bson.M{campo: bson.M{"$lte": fecha}}, true
+}
+
+// filtroValoresTasks lee un parámetro con uno o varios valores separados por coma y regresa la
+// condición sobre el campo del mismo nombre
+func filtroValoresTasks(c *fiber.Ctx, param string, valido func(string) bool, mensaje string, errores erroresCampo) (bson.M, bool) {
+    valor := c.Query(param)
+    if valor == "" {
+        return nil, false
+    }
+    valores := bson.A{}
+    for _, v := range strings.Split(valor, ",") {
+        v = strings.TrimSpace(v)
+        if !valido(v) {
+            errores.agregar(param, mensaje)
+            return nil, false
+        }
+        valores = append(valores, v)
+    }
+    return bson.M{param: bson.M{"$in": valores}}, true
+}
 
-    var tasks []models.Task
-    if err := cursor.All(ctx, &tasks); err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer tasks"})
+// claveTask regresa el valor del campo de orden de una task, para armar los cursores
+func claveTask(campo string) func(models.Task) (interface{}, primitive.ObjectID) {
+    return func(t models.Task) (interface{}, primitive.ObjectID) {
+        switch campo {
+        case "fecha_inicio":
+            return t.FechaInicio, t.ID
+        case "fecha_final":
+            return t.FechaFinal, t.ID
+        case "titulo":
+            return t.Titulo, t.ID
+        }
+        return nil, t.ID
     }
-    return c.JSON(tasks)
+}
+
+// crearIndicesTasks crea los índices del listado de tasks, todos empiezan por usuario_id porque
+// cada usuario solo ve las suyas y terminan en _id que es el desempate de la paginación
+func crearIndicesTasks(ctx context.Context) error {
+    _, err := getCollectionTasks().Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "deleted_at", Value: 1}, {Key: "fecha_inicio", Value: 1}, {Key: "_id", Value: 1}},
+            Options: options.Index().SetName("usuario_fecha_inicio"),
+        },
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "deleted_at", Value: 1}, {Key: "fecha_final", Value: 1}, {Key: "_id", Value: 1}},
+            Options: options.Index().SetName("usuario_fecha_final"),
+        },
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "deleted_at", Value: 1}, {Key: "titulo", Value: 1}, {Key: "_id", Value: 1}},
+            Options: options.Index().SetName("usuario_titulo"),
+        },
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "deleted_at", Value: 1}, {Key: "_id", Value: 1}},
+            Options: options.Index().SetName("usuario_creada"),
+        },
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "deleted_at", Value: 1}, {Key: "estado", Value: 1}, {Key: "fecha_inicio", Value: 1}, {Key: "_id", Value: 1}},
+            Options: options.Index().SetName("usuario_estado"),
+        },
+        {
+            Keys:    bson.D{{Key: "serie_id", Value: 1}, {Key: "ocurrencia", Value: 1}},
+            Options: options.Index().SetName("serie_ocurrencia").SetSparse(true),
+        },
+    })
+    return err
+}
+
+// rangoTasks lee ?dia o ?semana y regresa el rango en UTC que corresponde en la zona del usuario
+func rangoTasks(c *fiber.Ctx, prefs models.Preferencias, errores erroresCampo) (time.Time, time.Time, bool) {
+    loc := prefs.Ubicacion()
+    if dia := c.Query("dia"); dia != "" {
+        fecha, err := time.ParseInLocation("2006-01-02", dia, loc)
+        if err != nil {
+            errores.agregar("dia", "Fecha inválida (formato YYYY-MM-DD)")
+            return time.Time{}, time.Time{}, false
+        }
+        inicio, fin := utils.RangoDia(fecha, loc)
+        return inicio, fin, true
+    }
+    if semana := c.Query("semana"); semana != "" {
+        fecha, err := time.ParseInLocation("2006-01-02", semana, loc)
+        if err != nil {
+            errores.agregar("semana", "Fecha inválida (formato YYYY-MM-DD)")
+            return time.Time{}, time.Time{}, false
+        }
+        inicio, fin := utils.RangoSemana(fecha, loc, prefs.DiaInicioSemana())
+        return inicio, fin, true
+    }
+    return time.Time{}, time.Time{}, false
+}
+
+// respuestasTasks convierte las tasks a su respuesta con las fechas en loc
+func respuestasTasks(tasks []models.Task, loc *time.Location) []models.TaskResponse {
+    lista := models.NewTaskResponses(tasks)
+    for i := range lista {
+        lista[i] = lista[i].EnZona(loc)
+    }
+    return lista
 }
 
 // GetTask obtiene una task específico, pero solo si pertenece al usuario
@@ -108,16 +285,25 @@ func GetTask(c *fiber.Ctx) error {
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, preferenciasUsuario(ctx, userIDHex), errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
     var task models.Task
-    filter := bson.M{"_id": taskID, "usuario_id": userObjID}
+    filter := bson.M{"_id": taskID, "usuario_id": userObjID, "deleted_at": nil}
     err = col.FindOne(ctx, filter).Decode(&task)
     if err != nil {
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada"})
     }
-    return c.JSON(task)
+    return c.JSON(models.NewTaskResponse(task).EnZona(loc))
 }
 
-// UpdateTask actualiza campos de una task pero solo si es del usuario
+// UpdateTask actualiza campos de una task pero solo si es del usuario. Solo acepta los campos de
+// camposEditablesTask y regresa los errores de todos juntos. Un cambio de estado tiene que ser una
+// transición permitida en models.TransicionesTask, si no se responde 409. En una serie,
+// ?ocurrencia y ?alcance (esta o siguientes) editan solo una ocurrencia o de esa en adelante
 func UpdateTask(c *fiber.Ctx) error {
     idParam := c.Params("id")
     taskID, err := primitive.ObjectIDFromHex(idParam)
@@ -127,43 +313,104 @@ func UpdateTask(c *fiber.Ctx) error {
     userIDHex := c.Locals("userID").(string)
     userObjID, _ := primitive.ObjectIDFromHex(userIDHex)
 
-    var updates map[string]interface{}
-    if err := c.BodyParser(&updates); err != nil {
+    var body TaskRequest
+    if err := c.BodyParser(&body); err != nil {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
 
-    // Si vienen fechas en string, parsearlas
-    if val, ok := updates["fecha_inicio"].(string); ok {
-        t, err := time.Parse(time.RFC3339, val)
-        if err != nil {
-            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Fecha de inicio inválida"})
-        }
-        updates["fecha_inicio"] = t
+    // Cualquier campo fuera de la lista blanca es un error, no se ignora en silencio
+    errores := erroresCampo{}
+    extra, err := camposNoPermitidos(c.Body(), camposEditablesTask...)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
-    if val, ok := updates["fecha_final"].(string); ok {
-        t, err := time.Parse(time.RFC3339, val)
-        if err != nil {
-            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "FechaFinal inválido"})
-        }
-        updates["fecha_final"] = t
+    for _, campo := range extra {
+        errores.agregar(campo, "El campo no se puede modificar")
     }
 
     col := getCollectionTasks()
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
-    filter := bson.M{"_id": taskID, "usuario_id": userObjID}
-    result, err := col.UpdateOne(ctx, filter, bson.M{"$set": updates})
+    filter := bson.M{"_id": taskID, "usuario_id": userObjID, "deleted_at": nil}
+    var task models.Task
+    if err := col.FindOne(ctx, filter).Decode(&task); err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+    }
+    anterior := task
+
+    // Las fechas que no traen offset son de la zona del usuario
+    loc := preferenciasUsuario(ctx, userIDHex).Ubicacion()
+    if c.Query("ocurrencia") != "" {
+        if hecho, err := editarOcurrencia(c, ctx, task, body, loc, errores); hecho {
+            return err
+        }
+    }
+    set, erroresValidacion := body.validar(&task, loc, false)
+    for campo, mensaje := range erroresValidacion {
+        errores.agregar(campo, mensaje)
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+    if len(set) == 0 {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No hay campos para actualizar"})
+    }
+
+    // El orden de las fechas y la transición se revisaron contra la task leída, si otra petición
+    // la cambió mientras tanto ya no coincide con el filtro y no se actualiza
+    update := bson.M{"$set": set}
+    _, cambiaInicio := set["fecha_inicio"]
+    _, cambiaFinal := set["fecha_final"]
+    if cambiaInicio || cambiaFinal {
+        filter["fecha_inicio"] = anterior.FechaInicio
+        filter["fecha_final"] = anterior.FechaFinal
+    }
+    // Con otra regla o con otro inicio las excepciones se recalcularon y las ocurrencias editadas
+    // aparte que ya no son de la serie se sueltan
+    reiniciaSerie := anterior.Recurrencia != nil && (body.RRule != nil || cambiaInicio)
+    if reiniciaSerie {
+        filter["recurrencia.rrule"] = anterior.Recurrencia.Regla
+        filter["recurrencia.excepciones"] = filtroExcepciones(*anterior.Recurrencia)
+    }
+    if task.Estado == anterior.Estado {
+        delete(set, "estado")
+    } else {
+        if !models.TransicionTaskValida(anterior.Estado, task.Estado) {
+            return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errTransicionTask{anterior.Estado, task.Estado}.Error()})
+        }
+        filter["estado"] = anterior.Estado
+        cambio := updateEstadoTask(task.Estado, time.Now().UTC())
+        for k, v := range cambio["$set"].(bson.M) {
+            set[k] = v
+        }
+        if unset, ok := cambio["$unset"]; ok {
+            update["$unset"] = unset
+        }
+    }
+    if len(set) == 0 {
+        // Solo venía el estado y la task ya lo tenía
+        return c.JSON(fiber.Map{"message": "Task actualizada exitosamente"})
+    }
+
+    result, err := col.UpdateOne(ctx, filter, update)
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la task"})
     }
     if result.MatchedCount == 0 {
-        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La task cambió mientras se actualizaba, intenta de nuevo"})
+    }
+    if reiniciaSerie {
+        if err := soltarOcurrencias(ctx, task); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la task"})
+        }
     }
     return c.JSON(fiber.Map{"message": "Task actualizada exitosamente"})
 }
 
-// DeleteTask, elimina una task, pwero solo si pertenece al usuario
+// DeleteTask, elimina una task, pwero solo si pertenece al usuario. Solo se marca con deleted_at,
+// el proceso de purga la borra para siempre después del periodo de gracia. En una serie,
+// ?ocurrencia y ?alcance borran solo una ocurrencia o de esa en adelante
 func DeleteTask(c *fiber.Ctx) error {
     idParam := c.Params("id")
     taskID, err := primitive.ObjectIDFromHex(idParam)
@@ -177,14 +424,30 @@ func DeleteTask(c *fiber.Ctx) error {
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
-    filter := bson.M{"_id": taskID, "usuario_id": userObjID}
-    result, err := col.DeleteOne(ctx, filter)
+    filter := bson.M{"_id": taskID, "usuario_id": userObjID, "deleted_at": nil}
+    if c.Query("ocurrencia") != "" {
+        var serie models.Task
+        if err := col.FindOne(ctx, filter).Decode(&serie); err != nil {
+            return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+        }
+        if hecho, err := borrarOcurrencia(c, ctx, serie, preferenciasUsuario(ctx, userIDHex).Ubicacion()); hecho {
+            return err
+        }
+    }
+
+    ahora := time.Now().UTC()
+    result, err := col.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"deleted_at": ahora}})
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo eliminar la task"})
     }
-    if result.DeletedCount == 0 {
+    if result.MatchedCount == 0 {
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
     }
+    // Las ocurrencias que se editaron aparte se borran con su serie
+    _, err = col.UpdateMany(ctx, bson.M{"serie_id": taskID, "usuario_id": userObjID, "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": ahora}})
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo eliminar la task"})
+    }
     return c.JSON(fiber.Map{"message": "Task eliminada exitosamente"})
 }
 
@@ -0,0 +1,562 @@
+// handlers/task_recurrence.go
+package handlers
+
+import (
+    "context"
+    "errors"
+    "log"
+    "sort"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// Límites de GET /api/tasks/agenda
+const (
+    maxDiasAgenda        = 366
+    maxOcurrenciasAgenda = 2000
+)
+
+// errSerieCambio se regresa cuando la serie cambió entre que se leyó y se guardó el cambio
+var errSerieCambio = errors.New("la serie cambió mientras se actualizaba")
+
+// reglaSerie lee la regla de repetición de la serie t
+func reglaSerie(t models.Task) (utils.Regla, error) {
+    return utils.ParsearRegla(t.Recurrencia.Regla, t.Recurrencia.Ubicacion())
+}
+
+// actualizarFinSerie calcula recurrencia.fin, el fin de la última ocurrencia de la serie, para
+// que las consultas por fechas puedan descartar las series que ya terminaron. También revisa que
+// fecha_inicio sea la primera ocurrencia de la regla
+func actualizarFinSerie(t *models.Task) error {
+    regla, err := reglaSerie(*t)
+    if err != nil {
+        return errors.New("Regla de repetición inválida: " + err.Error())
+    }
+    var primera, ultima *time.Time
+    regla.Recorrer(t.FechaInicio, t.Recurrencia.Ubicacion(), func(oc time.Time) bool {
+        if primera == nil {
+            primera = &oc
+        }
+        ultima = &oc
+        // De una serie sin fin basta saber que tiene al menos una ocurrencia
+        return regla.Finita()
+    })
+    if ultima == nil {
+        return errors.New("La regla de repetición no genera ninguna ocurrencia desde fecha_inicio")
+    }
+    // La task es la primera ocurrencia de la serie, si fecha_inicio no cumple la regla la agenda
+    // no la mostraría y la primera ocurrencia sería otra
+    if !primera.Equal(t.FechaInicio) {
+        return errors.New("fecha_inicio no cumple la regla de repetición, tiene que ser su primera ocurrencia")
+    }
+
+    rec := *t.Recurrencia
+    rec.Fin = nil
+    if regla.Finita() {
+        fin := ultima.Add(t.FechaFinal.Sub(t.FechaInicio))
+        rec.Fin = &fin
+    }
+    t.Recurrencia = &rec
+    return nil
+}
+
+// rebasarExcepciones deja en la serie t solo las excepciones que siguen siendo ocurrencias de su
+// regla desde fecha_inicio. Las demás eran de la regla o del inicio anterior y ya no quitan nada
+func rebasarExcepciones(t *models.Task) {
+    if len(t.Recurrencia.Excepciones) == 0 {
+        return
+    }
+    regla, err := reglaSerie(*t)
+    if err != nil {
+        return
+    }
+    ultima := t.Recurrencia.Excepciones[0]
+    for _, e := range t.Recurrencia.Excepciones {
+        if e.After(ultima) {
+            ultima = e
+        }
+    }
+    var vigentes []time.Time
+    regla.Recorrer(t.FechaInicio, t.Recurrencia.Ubicacion(), func(oc time.Time) bool {
+        if oc.After(ultima) {
+            return false
+        }
+        if t.Recurrencia.EsExcepcion(oc) {
+            vigentes = append(vigentes, oc)
+        }
+        return true
+    })
+    rec := *t.Recurrencia
+    rec.Excepciones = vigentes
+    t.Recurrencia = &rec
+}
+
+// soltarOcurrencias deja como tasks sueltas las ocurrencias editadas aparte de la serie que ya no
+// son excepciones de ella, porque la regla o fecha_inicio cambió y esa fecha ya no es de la serie.
+// Si la serie ya no se repite se sueltan todas
+func soltarOcurrencias(ctx context.Context, serie models.Task) error {
+    vigentes := []time.Time{}
+    if serie.Recurrencia != nil {
+        vigentes = append(vigentes, serie.Recurrencia.Excepciones...)
+    }
+    filter := bson.M{"serie_id": serie.ID, "usuario_id": serie.UsuarioID, "deleted_at": nil, "ocurrencia": bson.M{"$nin": vigentes}}
+    _, err := getCollectionTasks().UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"serie_id": "", "ocurrencia": ""}})
+    return err
+}
+
+// filtroExcepciones es la condición de que las excepciones de la serie sigan siendo las que se
+// leyeron, así un cambio de regla no pisa una ocurrencia que se separó mientras tanto
+func filtroExcepciones(rec models.Recurrencia) interface{} {
+    if len(rec.Excepciones) == 0 {
+        return bson.M{"$exists": false}
+    }
+    return rec.Excepciones
+}
+
+// ocurrenciasEnRango regresa los inicios de las ocurrencias de la serie t que se cruzan con
+// [desde, hasta), sin las excepciones. Se detiene al pasar de max
+func ocurrenciasEnRango(t models.Task, desde, hasta time.Time, max int) []time.Time {
+    regla, err := reglaSerie(t)
+    if err != nil {
+        return nil
+    }
+    duracion := t.FechaFinal.Sub(t.FechaInicio)
+    var lista []time.Time
+    regla.Recorrer(t.FechaInicio, t.Recurrencia.Ubicacion(), func(oc time.Time) bool {
+        if !oc.Before(hasta) {
+            return false
+        }
+        if oc.Add(duracion).Before(desde) || t.Recurrencia.EsExcepcion(oc) {
+            return true
+        }
+        lista = append(lista, oc)
+        return len(lista) <= max
+    })
+    return lista
+}
+
+// buscarOcurrencia revisa que la serie tenga una ocurrencia vigente que empiece en inicio. Regresa
+// también si es la primera de la serie y cuántas ocurrencias hay antes, contando las excepciones
+// porque COUNT también las cuenta
+func buscarOcurrencia(t models.Task, inicio time.Time) (existe, primera bool, antes int) {
+    regla, err := reglaSerie(t)
+    if err != nil {
+        return false, false, 0
+    }
+    regla.Recorrer(t.FechaInicio, t.Recurrencia.Ubicacion(), func(oc time.Time) bool {
+        if oc.Equal(inicio) {
+            existe = true
+            return false
+        }
+        if oc.After(inicio) {
+            return false
+        }
+        antes++
+        return true
+    })
+    return existe && !t.Recurrencia.EsExcepcion(inicio), antes == 0, antes
+}
+
+// leerOcurrencia lee ?ocurrencia (el inicio de la ocurrencia, como viene en la agenda) y ?alcance
+// (esta por defecto) de una operación sobre una ocurrencia de la serie t
+func leerOcurrencia(c *fiber.Ctx, t models.Task, loc *time.Location, errores erroresCampo) (time.Time, string, bool) {
+    alcance := c.Query("alcance", models.AlcanceEsta)
+    if alcance != models.AlcanceEsta && alcance != models.AlcanceSiguientes {
+        errores.agregar("alcance", "Debe ser esta o siguientes")
+    }
+    if t.Recurrencia == nil {
+        errores.agregar("ocurrencia", "La task no se repite")
+        return time.Time{}, alcance, false
+    }
+    inicio, err := utils.ParsearFechaLocal(c.Query("ocurrencia"), loc)
+    if err != nil {
+        errores.agregar("ocurrencia", "Fecha inválida (RFC 3339, YYYY-MM-DDTHH:MM o YYYY-MM-DD)")
+        return inicio, alcance, false
+    }
+    existe, primera, _ := buscarOcurrencia(t, inicio)
+    if !existe {
+        errores.agregar("ocurrencia", "La serie no tiene una ocurrencia que empiece en esa fecha")
+    }
+    return inicio, alcance, primera
+}
+
+// nuevaOcurrencia arma la task aparte para la ocurrencia de la serie que empieza en inicio, con
+// los datos de la serie
+func nuevaOcurrencia(serie models.Task, inicio time.Time) models.Task {
+    return models.Task{
+        ID:           primitive.NewObjectID(),
+        Titulo:       serie.Titulo,
+        Descripcion:  serie.Descripcion,
+        FechaInicio:  inicio,
+        FechaFinal:   inicio.Add(serie.FechaFinal.Sub(serie.FechaInicio)),
+        Estado:       serie.Estado,
+        Prioridad:    serie.Prioridad,
+        CompletadaEn: serie.CompletadaEn,
+        Checklist:    serie.Checklist,
+        UsuarioID:    serie.UsuarioID,
+        SerieID:      &serie.ID,
+        Ocurrencia:   &inicio,
+    }
+}
+
+// marcarEstado cambia el estado de una task que todavía no se guarda, con la misma regla de
+// completed_at que updateEstadoTask. Regresa errTransicionTask si el cambio no está permitido
+func marcarEstado(t *models.Task, desde string) error {
+    if t.Estado == desde {
+        return nil
+    }
+    if !models.TransicionTaskValida(desde, t.Estado) {
+        return errTransicionTask{desde, t.Estado}
+    }
+    t.CompletadaEn = nil
+    if t.Estado == models.EstadoTaskCompletada {
+        ahora := time.Now().UTC()
+        t.CompletadaEn = &ahora
+    }
+    return nil
+}
+
+// guardarOcurrencia guarda la ocurrencia oc como task aparte y la quita de su serie. La excepción
+// solo se agrega si no estaba, así dos peticiones no separan la misma ocurrencia dos veces, y si
+// la regla o fecha_inicio de la serie no cambiaron, porque entonces la ocurrencia ya no sería suya
+func guardarOcurrencia(ctx context.Context, serie, oc models.Task) error {
+    col := getCollectionTasks()
+    filter := bson.M{
+        "_id":                     serie.ID,
+        "deleted_at":              nil,
+        "fecha_inicio":            serie.FechaInicio,
+        "recurrencia.rrule":       serie.Recurrencia.Regla,
+        "recurrencia.excepciones": bson.M{"$ne": *oc.Ocurrencia},
+    }
+    result, err := col.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"recurrencia.excepciones": *oc.Ocurrencia}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return errSerieCambio
+    }
+    if _, err := col.InsertOne(ctx, oc); err != nil {
+        if _, errPull := col.UpdateOne(ctx, bson.M{"_id": serie.ID}, bson.M{"$pull": bson.M{"recurrencia.excepciones": *oc.Ocurrencia}}); errPull != nil {
+            log.Println("No se pudo devolver la ocurrencia", oc.Ocurrencia.UTC().Format(time.RFC3339), "a la serie", serie.ID.Hex(), ":", errPull)
+        }
+        return err
+    }
+    return nil
+}
+
+// cortarSerie termina la serie justo antes de la ocurrencia que empieza en inicio. Regresa la
+// recurrencia de la parte que sigue, con las excepciones que le tocan y el COUNT que le queda
+func cortarSerie(ctx context.Context, serie models.Task, inicio time.Time) (models.Recurrencia, error) {
+    regla, err := reglaSerie(serie)
+    if err != nil {
+        return models.Recurrencia{}, err
+    }
+    reglaAnterior, reglaSiguiente := regla.Dividir(serie.FechaInicio, inicio, serie.Recurrencia.Ubicacion())
+
+    siguiente := models.Recurrencia{ZonaHoraria: serie.Recurrencia.ZonaHoraria}
+    anterior := models.Recurrencia{ZonaHoraria: serie.Recurrencia.ZonaHoraria}
+    for _, e := range serie.Recurrencia.Excepciones {
+        if e.Before(inicio) {
+            anterior.Excepciones = append(anterior.Excepciones, e)
+        } else {
+            siguiente.Excepciones = append(siguiente.Excepciones, e)
+        }
+    }
+
+    siguiente.Regla = reglaSiguiente.String()
+    anterior.Regla = reglaAnterior.String()
+
+    cortada := serie
+    cortada.Recurrencia = &anterior
+    if err := actualizarFinSerie(&cortada); err != nil {
+        return siguiente, err
+    }
+
+    // Si la regla o las fechas cambiaron mientras tanto el corte ya no corresponde
+    filter := bson.M{"_id": serie.ID, "deleted_at": nil, "fecha_inicio": serie.FechaInicio, "recurrencia.rrule": serie.Recurrencia.Regla}
+    result, err := getCollectionTasks().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"recurrencia": *cortada.Recurrencia}})
+    if err != nil {
+        return siguiente, err
+    }
+    if result.MatchedCount == 0 {
+        return siguiente, errSerieCambio
+    }
+    return siguiente, nil
+}
+
+// editarOcurrencia aplica PUT /api/tasks/:id?ocurrencia=...&alcance=... sobre la serie. Con
+// alcance=esta la ocurrencia se guarda como task aparte; con siguientes la serie se corta y desde
+// esa ocurrencia empieza una serie nueva con los cambios. Regresa false si la ocurrencia es la
+// primera y el alcance es siguientes, eso es editar toda la serie
+func editarOcurrencia(c *fiber.Ctx, ctx context.Context, serie models.Task, body TaskRequest, loc *time.Location, errores erroresCampo) (bool, error) {
+    inicio, alcance, primera := leerOcurrencia(c, serie, loc, errores)
+    if len(errores) == 0 && alcance == models.AlcanceSiguientes && primera {
+        return false, nil
+    }
+
+    var task models.Task
+    if alcance == models.AlcanceEsta {
+        task = nuevaOcurrencia(serie, inicio)
+    } else {
+        task = serie
+        task.ID = primitive.NewObjectID()
+        task.FechaInicio = inicio
+        task.FechaFinal = inicio.Add(serie.FechaFinal.Sub(serie.FechaInicio))
+    }
+    _, erroresValidacion := body.validar(&task, loc, false)
+    for campo, mensaje := range erroresValidacion {
+        errores.agregar(campo, mensaje)
+    }
+    if len(errores) > 0 {
+        return true, responderValidacion(c, errores)
+    }
+    if err := marcarEstado(&task, serie.Estado); err != nil {
+        return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+    }
+
+    var err error
+    if alcance == models.AlcanceEsta {
+        err = guardarOcurrencia(ctx, serie, task)
+    } else {
+        err = dividirSerie(ctx, serie, inicio, task, body.RRule != nil)
+    }
+    if err == errSerieCambio {
+        return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La task cambió mientras se actualizaba, intenta de nuevo"})
+    }
+    if err != nil {
+        return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la task"})
+    }
+    return true, c.JSON(fiber.Map{"message": "Task actualizada exitosamente", "task": models.NewTaskResponse(task).EnZona(loc)})
+}
+
+// dividirSerie corta la serie en inicio y guarda nueva como la serie que sigue. Si no se mandó
+// otra regla, nueva se queda con la regla de la serie y el COUNT que le faltaba. Las ocurrencias
+// editadas aparte desde inicio pasan a la serie nueva, o quedan sueltas si ya no son de ella
+func dividirSerie(ctx context.Context, serie models.Task, inicio time.Time, nueva models.Task, cambiaRegla bool) error {
+    siguiente, err := cortarSerie(ctx, serie, inicio)
+    if err != nil {
+        return err
+    }
+    if nueva.Recurrencia != nil && !cambiaRegla {
+        nueva.Recurrencia = &siguiente
+    }
+    if nueva.Recurrencia != nil {
+        rebasarExcepciones(&nueva)
+        if err := actualizarFinSerie(&nueva); err != nil {
+            return err
+        }
+    }
+
+    col := getCollectionTasks()
+    if _, err := col.InsertOne(ctx, nueva); err != nil {
+        if _, errRestaurar := col.UpdateOne(ctx, bson.M{"_id": serie.ID}, bson.M{"$set": bson.M{"recurrencia": *serie.Recurrencia}}); errRestaurar != nil {
+            log.Println("No se pudo restaurar la regla de la serie", serie.ID.Hex(), ":", errRestaurar)
+        }
+        return err
+    }
+    _, err = col.UpdateMany(ctx, bson.M{"serie_id": serie.ID, "ocurrencia": bson.M{"$gte": inicio}, "deleted_at": nil}, bson.M{"$set": bson.M{"serie_id": nueva.ID}})
+    if err != nil {
+        return err
+    }
+    return soltarOcurrencias(ctx, nueva)
+}
+
+// borrarOcurrencia aplica DELETE /api/tasks/:id?ocurrencia=...&alcance=... sobre la serie. Con
+// alcance=esta la ocurrencia se quita como excepción; con siguientes la serie termina antes de
+// ella. Regresa false si hay que borrar la serie completa
+func borrarOcurrencia(c *fiber.Ctx, ctx context.Context, serie models.Task, loc *time.Location) (bool, error) {
+    errores := erroresCampo{}
+    inicio, alcance, primera := leerOcurrencia(c, serie, loc, errores)
+    if len(errores) > 0 {
+        return true, responderValidacion(c, errores)
+    }
+    if alcance == models.AlcanceSiguientes && primera {
+        return false, nil
+    }
+
+    col := getCollectionTasks()
+    var err error
+    if alcance == models.AlcanceEsta {
+        filter := bson.M{"_id": serie.ID, "deleted_at": nil, "recurrencia.excepciones": bson.M{"$ne": inicio}}
+        result, errUpdate := col.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"recurrencia.excepciones": inicio}})
+        err = errUpdate
+        if err == nil && result.MatchedCount == 0 {
+            err = errSerieCambio
+        }
+    } else {
+        _, err = cortarSerie(ctx, serie, inicio)
+        if err == nil {
+            // Las ocurrencias editadas aparte desde ese día también se borran
+            filter := bson.M{"serie_id": serie.ID, "ocurrencia": bson.M{"$gte": inicio}, "deleted_at": nil}
+            _, err = col.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"deleted_at": time.Now().UTC()}})
+        }
+    }
+    if err == errSerieCambio {
+        return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La task cambió mientras se actualizaba, intenta de nuevo"})
+    }
+    if err != nil {
+        return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo eliminar la task"})
+    }
+    return true, c.JSON(fiber.Map{"message": "Task eliminada exitosamente"})
+}
+
+// completarOcurrencia aplica POST /api/tasks/:id/complete?ocurrencia=...: la ocurrencia se guarda
+// aparte como completada y el resto de la serie no cambia
+func completarOcurrencia(c *fiber.Ctx) error {
+    taskID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+    userIDHex := c.Locals("userID").(string)
+    userObjID, _ := primitive.ObjectIDFromHex(userIDHex)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    prefs := preferenciasUsuario(ctx, userIDHex)
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, prefs, errores)
+
+    var serie models.Task
+    filter := bson.M{"_id": taskID, "usuario_id": userObjID, "deleted_at": nil}
+    if err := getCollectionTasks().FindOne(ctx, filter).Decode(&serie); err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+    }
+    inicio, alcance, _ := leerOcurrencia(c, serie, prefs.Ubicacion(), errores)
+    if alcance != models.AlcanceEsta {
+        errores.agregar("alcance", "Solo se puede completar una ocurrencia a la vez")
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    oc := nuevaOcurrencia(serie, inicio)
+    oc.Estado = models.EstadoTaskCompletada
+    if err := marcarEstado(&oc, serie.Estado); err != nil {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+    }
+    err = guardarOcurrencia(ctx, serie, oc)
+    if err == errSerieCambio {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "La task cambió mientras se actualizaba, intenta de nuevo"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la task"})
+    }
+    return c.JSON(fiber.Map{"message": "Task actualizada exitosamente", "task": models.NewTaskResponse(oc).EnZona(loc)})
+}
+
+// GetAgenda regresa las tasks que caen en una ventana de fechas con las series ya expandidas:
+// cada ocurrencia trae el id de su serie y "ocurrencia", que es lo que se manda para editarla o
+// completarla. La ventana es ?desde y ?hasta (un hasta con solo la fecha incluye ese día), o
+// ?dia / ?semana. También acepta los filtros estado y prioridad de GET /api/tasks
+func GetAgenda(c *fiber.Ctx) error {
+    userIDHex := c.Locals("userID").(string)
+    userObjID, err := primitive.ObjectIDFromHex(userIDHex)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    prefs := preferenciasUsuario(ctx, userIDHex)
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, prefs, errores)
+    desde, hasta := ventanaAgenda(c, prefs, errores)
+
+    condiciones := bson.A{
+        bson.M{"fecha_inicio": bson.M{"$lt": hasta}},
+        bson.M{"$or": bson.A{
+            bson.M{"recurrencia": nil, "fecha_final": bson.M{"$gte": desde}},
+            bson.M{"recurrencia": bson.M{"$ne": nil}, "$or": bson.A{bson.M{"recurrencia.fin": nil}, bson.M{"recurrencia.fin": bson.M{"$gte": desde}}}},
+        }},
+    }
+    if condicion, ok := filtroValoresTasks(c, "estado", models.EstadoTaskValido, mensajeEstadoTask, errores); ok {
+        condiciones = append(condiciones, condicion)
+    }
+    if condicion, ok := filtroValoresTasks(c, "prioridad", models.PrioridadValida, mensajePrioridad, errores); ok {
+        condiciones = append(condiciones, condicion)
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    filter := bson.M{"usuario_id": userObjID, "deleted_at": nil, "$and": condiciones}
+    cursor, err := getCollectionTasks().Find(ctx, filter)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al listar tasks"})
+    }
+    defer cursor.Close(ctx)
+
+    data := []models.TaskResponse{}
+    for cursor.Next(ctx) {
+        var task models.Task
+        if err := cursor.Decode(&task); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer tasks"})
+        }
+        if task.Recurrencia == nil {
+            data = append(data, models.NewTaskResponse(task).EnZona(loc))
+        } else {
+            for _, inicio := range ocurrenciasEnRango(task, desde, hasta, maxOcurrenciasAgenda) {
+                data = append(data, models.NewOcurrenciaResponse(task, inicio).EnZona(loc))
+            }
+        }
+        if len(data) > maxOcurrenciasAgenda {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La ventana tiene demasiadas tasks, usa un rango más corto"})
+        }
+    }
+    if err := cursor.Err(); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer tasks"})
+    }
+
+    sort.SliceStable(data, func(i, j int) bool {
+        if !data[i].FechaInicio.Equal(data[j].FechaInicio) {
+            return data[i].FechaInicio.Before(data[j].FechaInicio)
+        }
+        return data[i].ID < data[j].ID
+    })
+    return c.JSON(fiber.Map{"desde": desde.In(loc), "hasta": hasta.In(loc), "data": data})
+}
+
+// ventanaAgenda lee la ventana de la agenda, con ?dia o ?semana o con ?desde y ?hasta. El fin es exclusivo
+func ventanaAgenda(c *fiber.Ctx, prefs models.Preferencias, errores erroresCampo) (time.Time, time.Time) {
+    if inicio, fin, ok := rangoTasks(c, prefs, errores); ok {
+        return inicio, fin
+    }
+    if c.Query("dia") != "" || c.Query("semana") != "" {
+        return time.Time{}, time.Time{}
+    }
+    if c.Query("desde") == "" || c.Query("hasta") == "" {
+        errores.agregar("desde", "Indica desde y hasta, o dia o semana")
+        return time.Time{}, time.Time{}
+    }
+
+    loc := prefs.Ubicacion()
+    desde, err := utils.ParsearFechaLocal(c.Query("desde"), loc)
+    if err != nil {
+        errores.agregar("desde", "Fecha inválida (RFC 3339, YYYY-MM-DDTHH:MM o YYYY-MM-DD)")
+    }
+    hasta, errHasta := utils.ParsearFechaLocal(c.Query("hasta"), loc)
+    if errHasta != nil {
+        errores.agregar("hasta", "Fecha inválida (RFC 3339, YYYY-MM-DDTHH:MM o YYYY-MM-DD)")
+    }
+    if err != nil || errHasta != nil {
+        return desde, hasta
+    }
+    if len(c.Query("hasta")) == len("2006-01-02") {
+        hasta = hasta.In(loc).AddDate(0, 0, 1).UTC()
+    }
+    switch {
+    case !hasta.After(desde):
+        errores.agregar("hasta", "Debe ser posterior a desde")
+    case hasta.Sub(desde) > maxDiasAgenda*24*time.Hour:
+        errores.agregar("hasta", "La ventana no puede ser de más de 366 días")
+    }
+    return desde, hasta
+}
@@ -0,0 +1,107 @@
+// handlers/task_status.go
+package handlers
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// Mensajes de validación del estado y la prioridad
+const (
+    mensajeEstadoTask = "Estado inválido (pendiente, en_progreso, completada o cancelada)"
+    mensajePrioridad  = "Prioridad inválida (baja, media, alta o urgente)"
+)
+
+// errTransicionTask se regresa cuando la task no puede pasar al estado pedido desde el que tiene
+type errTransicionTask struct {
+    desde, hacia string
+}
+
+func (e errTransicionTask) Error() string {
+    if e.desde == e.hacia {
+        return "La task ya está " + e.hacia
+    }
+    return "No se puede pasar una task de " + e.desde + " a " + e.hacia
+}
+
+// CompleteTask marca la task :id como completada y guarda la fecha en completed_at. En una serie,
+// ?ocurrencia completa solo esa ocurrencia
+func CompleteTask(c *fiber.Ctx) error {
+    if c.Query("ocurrencia") != "" {
+        return completarOcurrencia(c)
+    }
+    return responderTransicionTask(c, models.EstadoTaskCompletada, models.OrigenesTransicion(models.EstadoTaskCompletada))
+}
+
+// ReopenTask regresa a pendiente una task completada o cancelada y quita completed_at
+func ReopenTask(c *fiber.Ctx) error {
+    return responderTransicionTask(c, models.EstadoTaskPendiente, []string{models.EstadoTaskCompletada, models.EstadoTaskCancelada})
+}
+
+// responderTransicionTask pasa la task :id del usuario al estado hacia si su estado actual está en desde
+func responderTransicionTask(c *fiber.Ctx, hacia string, desde []string) error {
+    taskID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+    userIDHex := c.Locals("userID").(string)
+    userObjID, _ := primitive.ObjectIDFromHex(userIDHex)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    errores := erroresCampo{}
+    loc := zonaRespuesta(c, preferenciasUsuario(ctx, userIDHex), errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
+    filter := bson.M{"_id": taskID, "usuario_id": userObjID, "deleted_at": nil}
+    task, err := transicionTask(ctx, filter, hacia, desde)
+    var errTransicion errTransicionTask
+    if errors.As(err, &errTransicion) {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errTransicion.Error()})
+    }
+    if err == mongo.ErrNoDocuments {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task no encontrada o no autorizada"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la task"})
+    }
+    return c.JSON(fiber.Map{"message": "Task actualizada exitosamente", "task": models.NewTaskResponse(task).EnZona(loc)})
+}
+
+// transicionTask cambia el estado de la task en una sola operación y solo si su estado actual
+// está en desde, así dos peticiones al mismo tiempo no se pisan
+func transicionTask(ctx context.Context, filter bson.M, hacia string, desde []string) (models.Task, error) {
+    var task models.Task
+    col := getCollectionTasks()
+    consulta := bson.M{"$and": bson.A{filter, bson.M{"estado": bson.M{"$in": desde}}}}
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+    err := col.FindOneAndUpdate(ctx, consulta, updateEstadoTask(hacia, time.Now().UTC()), opts).Decode(&task)
+    if err == mongo.ErrNoDocuments {
+        if errBuscar := col.FindOne(ctx, filter).Decode(&task); errBuscar != nil {
+            return task, err
+        }
+        return task, errTransicionTask{task.Estado, hacia}
+    }
+    return task, err
+}
+
+// updateEstadoTask arma el update para pasar una task a estado. completed_at se guarda al
+// completarla y se quita en cualquier otro estado
+func updateEstadoTask(estado string, ahora time.Time) bson.M {
+    if estado == models.EstadoTaskCompletada {
+        return bson.M{"$set": bson.M{"estado": estado, "completed_at": ahora}}
+    }
+    return bson.M{"$set": bson.M{"estado": estado}, "$unset": bson.M{"completed_at": ""}}
+}
@@ -0,0 +1,146 @@
+// handlers/task_validation.go
+package handlers
+
+import (
+    "strings"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// Límites de los textos de una task
+const (
+    longitudMaximaTitulo      = 200
+    longitudMaximaDescripcion = 5000
+)
+
+// TaskRequest son los campos de una task que manda el cliente. Al editar todos son opcionales,
+// solo se cambian los que vienen en el body
+type TaskRequest struct {
+    Titulo      *string `json:"titulo"`
+    Descripcion *string `json:"descripcion"`
+    FechaInicio *string `json:"fecha_inicio"` // RFC 3339 o local sin offset: "2006-01-02T15:04"
+    FechaFinal  *string `json:"fecha_final"`
+    Prioridad   *string `json:"prioridad"`
+    Estado      *string `json:"estado"` // solo al editar, las tasks nuevas empiezan pendientes
+    RRule       *string `json:"rrule"`  // regla de repetición, "" la quita
+}
+
+// Listas blancas de los campos que aceptan CreateTask y UpdateTask
+var (
+    camposCreacionTask  = []string{"titulo", "descripcion", "fecha_inicio", "fecha_final", "prioridad", "rrule"}
+    camposEditablesTask = []string{"titulo", "descripcion", "fecha_inicio", "fecha_final", "prioridad", "estado", "rrule"}
+)
+
+// validar revisa cada campo y lo aplica sobre t, que es la task nueva o la que se edita. Regresa
+// el $set con los campos que cambiaron y los errores de todos los campos. Al crear (nueva) el
+// título y las fechas son obligatorios; al editar el orden de las fechas solo se revisa si cambia
+// alguna, contra la que ya tenía la task si solo viene una
+func (r TaskRequest) validar(t *models.Task, loc *time.Location, nueva bool) (bson.M, erroresCampo) {
+    set := bson.M{}
+    errores := erroresCampo{}
+
+    if r.Titulo != nil || nueva {
+        titulo := ""
+        if r.Titulo != nil {
+            titulo = strings.TrimSpace(*r.Titulo)
+        }
+        switch {
+        case titulo == "":
+            errores.agregar("titulo", "El título es obligatorio")
+        case len([]rune(titulo)) > longitudMaximaTitulo:
+            errores.agregar("titulo", "El título es demasiado largo")
+        default:
+            t.Titulo = titulo
+            set["titulo"] = titulo
+        }
+    }
+    if r.Descripcion != nil {
+        descripcion := strings.TrimSpace(*r.Descripcion)
+        if len([]rune(descripcion)) > longitudMaximaDescripcion {
+            errores.agregar("descripcion", "La descripción es demasiado larga")
+        } else {
+            t.Descripcion = descripcion
+            set["descripcion"] = descripcion
+        }
+    }
+
+    fechasValidas := true
+    leerFecha := func(campo string, valor *string, destino *time.Time) {
+        if valor == nil && !nueva {
+            return
+        }
+        if valor == nil || strings.TrimSpace(*valor) == "" {
+            errores.agregar(campo, "La fecha es obligatoria")
+            fechasValidas = false
+            return
+        }
+        fecha, err := utils.ParsearFechaLocal(strings.TrimSpace(*valor), loc)
+        if err != nil {
+            errores.agregar(campo, "Fecha inválida (RFC 3339, YYYY-MM-DDTHH:MM o YYYY-MM-DD)")
+            fechasValidas = false
+            return
+        }
+        *destino = fecha
+        set[campo] = fecha
+    }
+    leerFecha("fecha_inicio", r.FechaInicio, &t.FechaInicio)
+    leerFecha("fecha_final", r.FechaFinal, &t.FechaFinal)
+    cambiaFechas := nueva || r.FechaInicio != nil || r.FechaFinal != nil
+    if fechasValidas && cambiaFechas && t.FechaFinal.Before(t.FechaInicio) {
+        errores.agregar("fecha_final", "La fecha final no puede ser anterior a la fecha de inicio")
+    }
+
+    if r.Prioridad != nil {
+        if !models.PrioridadValida(*r.Prioridad) {
+            errores.agregar("prioridad", mensajePrioridad)
+        } else {
+            t.Prioridad = *r.Prioridad
+            set["prioridad"] = *r.Prioridad
+        }
+    }
+    if r.Estado != nil {
+        if !models.EstadoTaskValido(*r.Estado) {
+            errores.agregar("estado", mensajeEstadoTask)
+        } else {
+            t.Estado = *r.Estado
+            set["estado"] = *r.Estado
+        }
+    }
+
+    if r.RRule != nil {
+        regla, err := utils.ParsearRegla(*r.RRule, loc)
+        switch {
+        case t.SerieID != nil:
+            errores.agregar("rrule", "Una ocurrencia editada aparte no se puede repetir")
+        case strings.TrimSpace(*r.RRule) == "":
+            t.Recurrencia = nil
+            set["recurrencia"] = nil
+        case err != nil:
+            errores.agregar("rrule", "Regla de repetición inválida: "+err.Error())
+        default:
+            // Las excepciones se conservan, son fechas que el usuario ya quitó de la serie. Abajo se
+            // quitan las que ya no son ocurrencias de la regla nueva
+            rec := models.Recurrencia{Regla: regla.String(), ZonaHoraria: loc.String()}
+            if t.Recurrencia != nil {
+                rec.Excepciones = t.Recurrencia.Excepciones
+            }
+            t.Recurrencia = &rec
+        }
+    }
+    // Con otra regla o con otras fechas cambia el fin de la serie
+    if t.Recurrencia != nil && fechasValidas && (r.RRule != nil || cambiaFechas) && errores["rrule"] == "" {
+        if r.RRule != nil || r.FechaInicio != nil {
+            rebasarExcepciones(t)
+        }
+        if err := actualizarFinSerie(t); err != nil {
+            errores.agregar("rrule", err.Error())
+        } else {
+            set["recurrencia"] = *t.Recurrencia
+        }
+    }
+    return set, errores
+}
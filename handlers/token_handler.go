@@ -0,0 +1,205 @@
+// handlers/token_handler.go
+package handlers
+
+import (
+    "context"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// getCollectionRefreshTokens devuelve la colección "refresh_tokens"
+func getCollectionRefreshTokens() *mongo.Collection {
+    return config.ClientMongo.Database(config.DBName).Collection("refresh_tokens")
+}
+
+// emitirTokens genera el par access/refresh para un usuario, el refresh token
+// se guarda (hasheado) dentro de la familia indicada
+func emitirTokens(ctx context.Context, user models.User, familiaID primitive.ObjectID) (fiber.Map, error) {
+    accessToken, err := utils.GenerarToken(user)
+    if err != nil {
+        return nil, err
+    }
+
+    refreshToken, hash, err := utils.GenerarRefreshToken()
+    if err != nil {
+        return nil, err
+    }
+
+    ahora := time.Now()
+    doc := models.RefreshToken{
+        ID:        primitive.NewObjectID(),
+        UsuarioID: user.ID,
+        FamiliaID: familiaID,
+        TokenHash: hash,
+        CreadoEn:  ahora,
+        ExpiraEn:  ahora.Add(utils.DuracionRefreshToken),
+    }
+    if _, err := getCollectionRefreshTokens().InsertOne(ctx, doc); err != nil {
+        return nil, err
+    }
+
+    return fiber.Map{
+        "token":         accessToken,
+        "refresh_token": refreshToken,
+        "expires_in":    int(utils.DuracionAccessToken.Seconds()),
+    }, nil
+}
+
+// RefreshToken cambia un refresh token válido por un par nuevo (rotación).
+// Si llega un refresh token que ya se usó se asume que fue robado y se revoca toda su familia
+func RefreshToken(c *fiber.Ctx) error {
+    type Request struct {
+        RefreshToken string `json:"refresh_token"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil || body.RefreshToken == "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    col := getCollectionRefreshTokens()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    hash := utils.HashToken(body.RefreshToken)
+    ahora := time.Now()
+
+    // Se marca como usado de forma atómica, asi dos peticiones con el mismo token no pueden rotarlo las dos
+    var actual models.RefreshToken
+    filter := bson.M{"token_hash": hash, "usado_en": nil, "revocado": false, "expira_en": bson.M{"$gt": ahora}}
+    err := col.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"usado_en": ahora}}).Decode(&actual)
+    if err == mongo.ErrNoDocuments {
+        // Si el token existe pero ya se usó o está revocado es un replay: se revoca la familia completa
+        var previo models.RefreshToken
+        if col.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&previo) == nil && previo.UsadoEn != nil {
+            if _, err := col.UpdateMany(ctx, bson.M{"familia_id": previo.FamiliaID}, bson.M{"$set": bson.M{"revocado": true}}); err != nil {
+                return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al revocar sesión"})
+            }
+            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token reutilizado, la sesión fue revocada"})
+        }
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token inválido o expirado"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al validar refresh token"})
+    }
+
+    var user models.User
+    if err := getCollectionUsers().FindOne(ctx, bson.M{"_id": actual.UsuarioID, "deleted_at": nil}).Decode(&user); err != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if inactiva, err := responderEstadoCuenta(c, user); inactiva {
+        return err
+    }
+
+    tokens, err := emitirTokens(ctx, user, actual.FamiliaID)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+    }
+    return c.JSON(tokens)
+}
+
+// revocarRefreshTokensUsuario revoca todos los refresh tokens activos del usuario
+func revocarRefreshTokensUsuario(ctx context.Context, userID primitive.ObjectID) error {
+    _, err := getCollectionRefreshTokens().UpdateMany(ctx, bson.M{"usuario_id": userID, "revocado": false}, bson.M{"$set": bson.M{"revocado": true}})
+    return err
+}
+
+// revocarAccessTokensUsuario revoca todos los tokens de acceso personal activos del usuario
+func revocarAccessTokensUsuario(ctx context.Context, userID primitive.ObjectID) error {
+    _, err := getCollectionAccessTokens().UpdateMany(ctx, bson.M{"usuario_id": userID, "revocado": false}, bson.M{"$set": bson.M{"revocado": true}})
+    return err
+}
+
+// cerrarSesiones invalida todos los JWT, refresh tokens y tokens de acceso personal del usuario.
+// Los tokens personales también caen porque alguien con la contraseña robada pudo haberlos creado
+func cerrarSesiones(ctx context.Context, userID primitive.ObjectID) error {
+    if err := utils.RevocarTokensUsuario(ctx, userID); err != nil {
+        return err
+    }
+    if err := revocarRefreshTokensUsuario(ctx, userID); err != nil {
+        return err
+    }
+    return revocarAccessTokensUsuario(ctx, userID)
+}
+
+// Logout revoca el JWT con el que se hizo la petición, si también se manda el
+// refresh token se revoca toda su familia para que la sesión no pueda renovarse
+func Logout(c *fiber.Ctx) error {
+    type Request struct {
+        RefreshToken string `json:"refresh_token"`
+    }
+    var body Request
+    if len(c.Body()) > 0 {
+        if err := c.BodyParser(&body); err != nil {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+        }
+    }
+
+    claims := c.Locals("claims").(*utils.ClaimsPersonalizados)
+    userObjID, err := primitive.ObjectIDFromHex(claims.UserID)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    if err := utils.RevocarToken(ctx, claims); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo cerrar la sesión"})
+    }
+
+    if body.RefreshToken != "" {
+        col := getCollectionRefreshTokens()
+        var rt models.RefreshToken
+        filter := bson.M{"token_hash": utils.HashToken(body.RefreshToken), "usuario_id": userObjID}
+        if col.FindOne(ctx, filter).Decode(&rt) == nil {
+            if _, err := col.UpdateMany(ctx, bson.M{"familia_id": rt.FamiliaID}, bson.M{"$set": bson.M{"revocado": true}}); err != nil {
+                return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo cerrar la sesión"})
+            }
+        }
+    }
+
+    return c.JSON(fiber.Map{"message": "Sesión cerrada exitosamente"})
+}
+
+// LogoutAll cierra todas las sesiones del usuario en todos sus dispositivos
+func LogoutAll(c *fiber.Ctx) error {
+    userIDHex := c.Locals("userID").(string)
+    userObjID, err := primitive.ObjectIDFromHex(userIDHex)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "UserID inválido"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    if err := cerrarSesiones(ctx, userObjID); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudieron cerrar las sesiones"})
+    }
+    return c.JSON(fiber.Map{"message": "Se cerraron todas las sesiones"})
+}
+
+// GetJWKS publica las llaves públicas del keyring para que otros servicios verifiquen nuestros JWT
+func GetJWKS(c *fiber.Ctx) error {
+    c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+    return c.JSON(fiber.Map{"keys": utils.JWKS()})
+}
+
+// crearIndicesRefreshTokens crea los índices de "refresh_tokens", el TTL borra los tokens ya expirados
+func crearIndicesRefreshTokens(ctx context.Context) error {
+    _, err := getCollectionRefreshTokens().Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "familia_id", Value: 1}}},
+        {Keys: bson.D{{Key: "usuario_id", Value: 1}}},
+        {Keys: bson.D{{Key: "expira_en", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+    })
+    return err
+}
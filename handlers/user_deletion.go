@@ -0,0 +1,175 @@
+// handlers/user_deletion.go
+package handlers
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// eliminarUsuario marca la cuenta y sus tasks como eliminadas con la misma fecha, así al
+// restaurar se regresan solo las tasks que cayeron con la cuenta. Regresa false si no existe
+func eliminarUsuario(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+    // Mongo guarda milisegundos, se trunca para poder comparar la fecha al restaurar
+    ahora := time.Now().UTC().Truncate(time.Millisecond)
+
+    result, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": userID, "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": ahora}})
+    if err != nil {
+        return false, err
+    }
+    if result.MatchedCount == 0 {
+        return false, nil
+    }
+
+    if _, err := getCollectionTasks().UpdateMany(ctx, bson.M{"usuario_id": userID, "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": ahora}}); err != nil {
+        return true, err
+    }
+    return true, cerrarSesiones(ctx, userID)
+}
+
+// RestoreUser recupera una cuenta eliminada hace menos de config.PurgaGracia, junto con las tasks
+// que se eliminaron con ella (solo administradores). Las sesiones y tokens de acceso no se recuperan
+func RestoreUser(c *fiber.Ctx) error {
+    idParam := c.Params("id")
+    objectID, err := primitive.ObjectIDFromHex(idParam)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
+    }
+
+    col := getCollectionUsers()
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    // Pasada la gracia la cuenta ya no se restaura aunque la purga todavía no la haya borrado
+    var user models.User
+    limite := time.Now().Add(-config.PurgaGracia)
+    filter := bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil, "$gte": limite}}
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+    err = col.FindOneAndUpdate(ctx, filter, bson.M{"$unset": bson.M{"deleted_at": ""}}, opts).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        if col.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": bson.M{"$lt": limite}}).Err() == nil {
+            return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "El plazo para restaurar la cuenta ya venció"})
+        }
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario eliminado no encontrado"})
+    }
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo restaurar el usuario"})
+    }
+
+    taskFilter := bson.M{"usuario_id": objectID, "deleted_at": user.EliminadoEn}
+    if _, err := getCollectionTasks().UpdateMany(ctx, taskFilter, bson.M{"$unset": bson.M{"deleted_at": ""}}); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudieron restaurar las tasks"})
+    }
+
+    user.EliminadoEn = nil
+    return c.JSON(models.NewUserResponse(user, models.VistaAdmin))
+}
+
+// IniciarPurga arranca en segundo plano el proceso que borra para siempre las cuentas y tasks
+// eliminadas hace más de config.PurgaGracia y las exportaciones expiradas, corre al arrancar y
+// luego cada config.PurgaIntervalo
+func IniciarPurga() {
+    go func() {
+        for {
+            purgarEliminados()
+            purgarExportaciones()
+            time.Sleep(config.PurgaIntervalo)
+        }
+    }()
+}
+
+// purgarEliminados borra las cuentas vencidas con todo lo que les pertenece, y las tasks que se
+// eliminaron sueltas. Los errores solo se registran, el siguiente ciclo lo vuelve a intentar
+func purgarEliminados() {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+    defer cancel()
+
+    limite := bson.M{"deleted_at": bson.M{"$lt": time.Now().UTC().Add(-config.PurgaGracia)}}
+
+    opts := options.Find().SetProjection(bson.M{"_id": 1, "email": 1, "avatar": 1})
+    cursor, err := getCollectionUsers().Find(ctx, limite, opts)
+    if err != nil {
+        log.Println("Purga: error al buscar cuentas eliminadas:", err)
+        return
+    }
+    var users []models.User
+    if err := cursor.All(ctx, &users); err != nil {
+        log.Println("Purga: error al leer cuentas eliminadas:", err)
+        return
+    }
+
+    purgadas := 0
+    for _, u := range users {
+        if err := purgarUsuario(ctx, u); err != nil {
+            log.Println("Purga: no se pudo borrar la cuenta", u.ID.Hex(), ":", err)
+            continue
+        }
+        purgadas++
+    }
+    if purgadas > 0 {
+        log.Println("Purga: se borraron", purgadas, "cuentas eliminadas")
+    }
+
+    result, err := getCollectionTasks().DeleteMany(ctx, limite)
+    if err != nil {
+        log.Println("Purga: error al borrar tasks eliminadas:", err)
+        return
+    }
+    if result.DeletedCount > 0 {
+        log.Println("Purga: se borraron", result.DeletedCount, "tasks eliminadas")
+    }
+}
+
+// purgarUsuario borra los datos del usuario y al final la cuenta, si algo falla la cuenta
+// sigue marcada y se vuelve a intentar en el siguiente ciclo
+func purgarUsuario(ctx context.Context, user models.User) error {
+    porUsuario := bson.M{"usuario_id": user.ID}
+    if _, err := getCollectionTasks().DeleteMany(ctx, porUsuario); err != nil {
+        return err
+    }
+    if _, err := getCollectionRefreshTokens().DeleteMany(ctx, porUsuario); err != nil {
+        return err
+    }
+    if _, err := getCollectionAccessTokens().DeleteMany(ctx, porUsuario); err != nil {
+        return err
+    }
+    if _, err := borrarExportaciones(ctx, porUsuario); err != nil {
+        return err
+    }
+    if err := limpiarFallos(ctx, claveEmail(user.Email)); err != nil {
+        return err
+    }
+    borrarArchivosAvatar(ctx, user.Avatar)
+    _, err := getCollectionUsers().DeleteOne(ctx, bson.M{"_id": user.ID})
+    return err
+}
+
+// crearIndicesEliminados crea los índices que usan el borrado lógico y la purga
+func crearIndicesEliminados(ctx context.Context) error {
+    if _, err := getCollectionUsers().Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+        Options: options.Index().SetName("deleted_at"),
+    }); err != nil {
+        return err
+    }
+    _, err := getCollectionTasks().Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "usuario_id", Value: 1}, {Key: "deleted_at", Value: 1}},
+            Options: options.Index().SetName("usuario_eliminado"),
+        },
+        {
+            Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+            Options: options.Index().SetName("deleted_at"),
+        },
+    })
+    return err
+}
@@ -0,0 +1,153 @@
+// handlers/user_emails.go
+package handlers
+
+import (
+    "context"
+    "log"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// collationEmail compara los emails sin distinguir mayúsculas (strength 2), la usan el
+// índice único y todas las búsquedas por email para que Mongo pueda usar ese índice
+var collationEmail = &options.Collation{Locale: "en", Strength: 2}
+
+// indiceEmailUnico indica si el índice único de email existe. Mientras falte (hay duplicados de
+// antes) el registro y el cambio de email revisan a mano que el email no lo tenga otra cuenta
+var indiceEmailUnico atomic.Bool
+
+// normalizarEmail deja el email sin espacios alrededor y en minúsculas
+func normalizarEmail(email string) string {
+    return strings.ToLower(strings.TrimSpace(email))
+}
+
+// buscarUsuarioPorEmail busca la cuenta sin importar mayúsculas y minúsculas
+func buscarUsuarioPorEmail(ctx context.Context, email string) (models.User, error) {
+    var user models.User
+    opts := options.FindOne().SetCollation(collationEmail)
+    err := getCollectionUsers().FindOne(ctx, bson.M{"email": normalizarEmail(email), "deleted_at": nil}, opts).Decode(&user)
+    return user, err
+}
+
+// emailOcupado indica si otra cuenta distinta de excluir ya usa el email, sin importar mayúsculas.
+// Solo hace falta mientras no existe el índice único, con el índice se regresa false sin consultar
+// y el duplicado lo detecta el insert o el update. Como el índice, cuenta también las eliminadas
+func emailOcupado(ctx context.Context, email string, excluir primitive.ObjectID) (bool, error) {
+    if indiceEmailUnico.Load() {
+        return false, nil
+    }
+    filter := bson.M{"email": normalizarEmail(email)}
+    if !excluir.IsZero() {
+        filter["_id"] = bson.M{"$ne": excluir}
+    }
+    opts := options.Count().SetCollation(collationEmail).SetLimit(1)
+    n, err := getCollectionUsers().CountDocuments(ctx, filter, opts)
+    return n > 0, err
+}
+
+// grupoDuplicado son las cuentas que comparten el mismo email ignorando mayúsculas
+type grupoDuplicado struct {
+    Email   string               `json:"email" bson:"_id"`
+    Cuentas []primitive.ObjectID `json:"cuentas" bson:"cuentas"`
+}
+
+// emailsDuplicados busca cuentas con el mismo email en distinta capitalización, son las que
+// impiden crear el índice único y hay que resolverlas a mano (fusionar o borrar)
+func emailsDuplicados(ctx context.Context) ([]grupoDuplicado, error) {
+    pipeline := mongo.Pipeline{
+        {{Key: "$group", Value: bson.M{
+            "_id":     bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}},
+            "cuentas": bson.M{"$push": "$_id"},
+            "total":   bson.M{"$sum": 1},
+        }}},
+        {{Key: "$match", Value: bson.M{"total": bson.M{"$gt": 1}}}},
+        {{Key: "$sort", Value: bson.M{"_id": 1}}},
+    }
+    cursor, err := getCollectionUsers().Aggregate(ctx, pipeline)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    grupos := []grupoDuplicado{}
+    if err := cursor.All(ctx, &grupos); err != nil {
+        return nil, err
+    }
+    return grupos, nil
+}
+
+// crearIndicesUsers crea el índice único de email. Si hay cuentas duplicadas de antes el índice
+// no se puede crear: se reportan en el log y el servidor arranca igual para poder resolverlas,
+// con indiceEmailUnico en false para que emailOcupado siga evitando duplicados nuevos
+func crearIndicesUsers(ctx context.Context) error {
+    _, err := getCollectionUsers().Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "email", Value: 1}},
+        Options: options.Index().SetName("email_unico").SetUnique(true).SetCollation(collationEmail),
+    })
+    indiceEmailUnico.Store(err == nil)
+    if err == nil || !mongo.IsDuplicateKeyError(err) {
+        return err
+    }
+
+    grupos, errReporte := emailsDuplicados(ctx)
+    if errReporte != nil {
+        return errReporte
+    }
+    log.Println("Aviso: no se pudo crear el índice único de email, hay", len(grupos), "emails duplicados:")
+    for _, g := range grupos {
+        log.Println("  ", g.Email, g.Cuentas)
+    }
+    log.Println("Mientras tanto el registro y el cambio de email revisan los duplicados sin el índice")
+    log.Println("Resuelve los duplicados (GET /api/admin/users/duplicate-emails) y reinicia el servidor")
+    return nil
+}
+
+// migrarEmails pasa a minúsculas los emails guardados antes de normalizarlos,
+// salvo los que tienen duplicados porque esos necesitan revisión manual
+func migrarEmails(ctx context.Context) error {
+    grupos, err := emailsDuplicados(ctx)
+    if err != nil {
+        return err
+    }
+    var excluidos []primitive.ObjectID
+    for _, g := range grupos {
+        excluidos = append(excluidos, g.Cuentas...)
+    }
+
+    filter := bson.M{
+        "_id":   bson.M{"$nin": excluidos},
+        "$expr": bson.M{"$ne": bson.A{"$email", bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}}}},
+    }
+    update := mongo.Pipeline{
+        {{Key: "$set", Value: bson.M{"email": bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}}}}},
+    }
+    result, err := getCollectionUsers().UpdateMany(ctx, filter, update)
+    if err != nil {
+        return err
+    }
+    if result.ModifiedCount > 0 {
+        log.Println("Migración: se normalizaron", result.ModifiedCount, "emails")
+    }
+    return nil
+}
+
+// GetDuplicateEmails reporta las cuentas con emails duplicados (solo administradores)
+func GetDuplicateEmails(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+
+    grupos, err := emailsDuplicados(ctx)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al buscar duplicados"})
+    }
+    return c.JSON(grupos)
+}
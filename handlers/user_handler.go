@@ -3,12 +3,16 @@ package handlers
 
 import (
     "context"
+    "log"
+    "regexp"
+    "strings"
     "time"
 
     "github.com/gofiber/fiber/v2"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/bson/primitive"
     "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
 
     "github.com/ImanolCE/api-rest-go/config"
     "github.com/ImanolCE/api-rest-go/models"
@@ -22,34 +26,25 @@ func getCollectionUsers() *mongo.Collection {
     return config.ClientMongo.Database(config.DBName).Collection("users")
 }
 
-// RegisterUser crea un usuario nuevo donde hace el hash de contraseña + guardado en DB
+// RegisterUser crea un usuario nuevo donde hace el hash de contraseña + guardado en DB.
+// La solicitud tiene que pasar las reglas de registro (reglasRegistro), si no se regresan
+// los motivos por campo
 func RegisterUser(c *fiber.Ctx) error {
-    type Request struct {
-        Nombre          string `json:"nombre"`
-        Apellidos       string `json:"apellidos"`
-        Email           string `json:"email"`
-        Password        string `json:"password"`
-        FechaNacimiento string `json:"fecha_nacimiento"` // ISO string: "2023-01-02"
-        PreguntaSecreta string `json:"pregunta_secreta"`
-        RespuestaSecreta string `json:"respuesta_secreta"`
-    }
-
-    var body Request
+    var body RegistroRequest
     if err := c.BodyParser(&body); err != nil {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
 
-    // Verificar que el email no exista
+    // Reglas de registro y preferencias opcionales, las que no vienen quedan con el valor por defecto
+    errores := body.validar()
+    preferencias := body.PreferenciasRequest.validar(errores)
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
     col := getCollectionUsers()
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
-    count, err := col.CountDocuments(ctx, bson.M{"email": body.Email})
-    if err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar email"})
-    }
-    if count > 0 {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email ya registrado"})
-    }
 
     // Hashear contraseña
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
@@ -57,10 +52,10 @@ func RegisterUser(c *fiber.Ctx) error {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al encriptar contraseña"})
     }
 
-    // Parsear fecha
-    fecha, err := time.Parse("2006-01-02", body.FechaNacimiento)
+    // La respuesta secreta se guarda igual que la contraseña, normalizada y hasheada
+    hashedRespuesta, err := utils.HashRespuesta(body.RespuestaSecreta)
     if err != nil {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Fecha de nacimiento inválida (formato YYYY-MM-DD)"})
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al encriptar respuesta secreta"})
     }
 
     newUser := models.User{
@@ -69,20 +64,62 @@ func RegisterUser(c *fiber.Ctx) error {
         Apellidos:        body.Apellidos,
         Email:            body.Email,
         Password:         string(hashedPassword),
-        FechaNacimiento:  fecha,
+        FechaNacimiento:  body.fechaNacimiento,
         PreguntaSecreta:  body.PreguntaSecreta,
-        RespuestaSecreta: body.RespuestaSecreta,
+        RespuestaSecreta: hashedRespuesta,
+        Rol:              models.RolUsuario,
+        Estado:           models.EstadoActivo,
+        EmailVerificado:  config.EmailVerificacion == config.VerificacionOff,
+        Preferencias:     preferencias,
     }
 
+    // Sin el índice único se revisa antes de gastar la invitación que el email no esté ocupado
+    ocupado, err := emailOcupado(ctx, body.Email, primitive.NilObjectID)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al revisar el email"})
+    }
+    if ocupado {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email ya registrado"})
+    }
+
+    // El código se gasta al final de las validaciones, si el registro falla se devuelve el uso
+    if config.RegistroSoloInvitacion {
+        inv, err := usarInvitacion(ctx, body.CodigoInvitacion, body.Email)
+        if err == mongo.ErrNoDocuments {
+            return responderValidacion(c, erroresCampo{"codigo_invitacion": "Código de invitación inválido, vencido o ya usado"})
+        }
+        if err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al revisar la invitación"})
+        }
+        newUser.InvitacionID = &inv.ID
+    }
+
+    // El índice único de email es el que evita duplicados, aun con dos registros al mismo tiempo.
+    // Si el índice falta por duplicados de antes solo queda la revisión de emailOcupado
     _, err = col.InsertOne(ctx, newUser)
+    if err != nil && newUser.InvitacionID != nil {
+        if errDevolver := devolverInvitacion(ctx, *newUser.InvitacionID); errDevolver != nil {
+            log.Println("No se pudo devolver el uso de la invitación", newUser.InvitacionID.Hex(), ":", errDevolver)
+        }
+    }
+    if mongo.IsDuplicateKeyError(err) {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email ya registrado"})
+    }
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al crear usuario"})
     }
 
+    // Si falla el correo el registro sigue siendo válido, el usuario puede pedir que se reenvíe
+    if config.EmailVerificacion != config.VerificacionOff {
+        if err := enviarVerificacion(ctx, newUser); err != nil {
+            log.Println("Error al enviar verificación a", newUser.Email, ":", err)
+        }
+    }
+
     return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Usuario registrado exitosamente"})
 }
 
-// LoginUser valida credenciales y retorna JWT
+// LoginUser valida credenciales y retorna el JWT de acceso junto con un refresh token
 func LoginUser(c *fiber.Ctx) error {
     type Request struct {
         Email    string `json:"email"`
@@ -93,51 +130,185 @@ func LoginUser(c *fiber.Ctx) error {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
 
-    col := getCollectionUsers()
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
-    var user models.User
-    err := col.FindOne(ctx, bson.M{"email": body.Email}).Decode(&user)
+    // Si el email o la IP están bloqueados por intentos fallidos ni siquiera se revisa la contraseña.
+    // El intento por email se cuenta antes de revisarla, así intentos en paralelo no se saltan la espera
+    porEmail, porIP := claveEmail(body.Email), claveIP(c.IP())
+    espera, err := tiempoBloqueo(ctx, porIP)
+    if err == nil && espera <= 0 {
+        espera, err = reservarIntento(ctx, porEmail, config.LoginMaxFallos)
+    }
     if err != nil {
-        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Email o contraseña incorrectos"})
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+    if espera > 0 {
+        return responderBloqueo(c, espera)
     }
 
-    // Comparar contraseña
-    if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)) != nil {
+    user, err := buscarUsuarioPorEmail(ctx, body.Email)
+    if err != nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)) != nil {
+        // Se cuentan los fallos aunque el email no exista, asi no se distingue una cuenta real
+        if registrarFallo(ctx, porIP, config.LoginMaxFallosIP) != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al registrar intento"})
+        }
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Email o contraseña incorrectos"})
     }
 
-    // Generar JWT
-    token, err := utils.GenerarToken(user.ID.Hex())
+    if err := limpiarFallos(ctx, porEmail); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+
+    // Las cuentas suspendidas o bloqueadas no pueden entrar aunque la contraseña sea correcta
+    if inactiva, err := responderEstadoCuenta(c, user); inactiva {
+        return err
+    }
+
+    // Con la política "block" no se puede entrar hasta verificar el email
+    if config.EmailVerificacion == config.VerificacionBloquea && !user.EmailVerificado {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Debes verificar tu email antes de iniciar sesión"})
+    }
+
+    // Con 2FA activo la contraseña solo da un token temporal que se cambia en /api/login/2fa
+    if user.TOTPActivo {
+        mfaToken, err := utils.GenerarTokenMFA(user)
+        if err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+        }
+        return c.JSON(fiber.Map{
+            "mfa_requerido": true,
+            "mfa_token":     mfaToken,
+            "expires_in":    int(utils.DuracionTokenMFA.Seconds()),
+        })
+    }
+
+    // Generar JWT + refresh token, cada login abre una familia nueva de refresh tokens
+    tokens, err := emitirTokens(ctx, user, primitive.NewObjectID())
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
     }
 
-    return c.JSON(fiber.Map{"token": token})
+    return c.JSON(tokens)
+}
+
+// ordenesUsuarios son los campos por los que se puede ordenar GET /api/users. La fecha de
+// registro sale del _id, así que ordenar por ella es ordenar por _id
+var ordenesUsuarios = map[string]utils.CampoOrden{
+    "nombre":         {Campo: "nombre", Tipo: bson.TypeString},
+    "apellidos":      {Campo: "apellidos", Tipo: bson.TypeString},
+    "email":          {Campo: "email", Tipo: bson.TypeString},
+    "fecha_registro": {Campo: "_id"},
 }
 
-// GetUsers lista todos los usuarios (solo para ejemplo; normalmente no expondrías datos sensibles)
+// GetUsers lista los usuarios paginados, la ruta solo está disponible para administradores.
+// Acepta limit, cursor y sort (?sort=-fecha_registro), filtros por prefijo de nombre y email,
+// rango de fecha de registro (registrado_desde, registrado_hasta), estado de la cuenta (status) y ?eliminados=true para
+// listar las cuentas eliminadas que todavía se pueden restaurar
 func GetUsers(c *fiber.Ctx) error {
-    col := getCollectionUsers()
+    errores := erroresCampo{}
+    pagina := leerPagina(c, ordenesUsuarios, "fecha_registro", errores)
+
+    filter := bson.M{"deleted_at": nil}
+    if c.QueryBool("eliminados") {
+        filter["deleted_at"] = bson.M{"$ne": nil}
+    }
+    switch estado := c.Query("status"); estado {
+    case "":
+    case models.EstadoActivo, models.EstadoSuspendido, models.EstadoBloqueado:
+        filter["status"] = estado
+    default:
+        errores.agregar("status", "Debe ser active, suspended o locked")
+    }
+    if nombre := strings.TrimSpace(c.Query("nombre")); nombre != "" {
+        filter["nombre"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(nombre), Options: "i"}
+    }
+    if email := normalizarEmail(c.Query("email")); email != "" {
+        filter["email"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(email)}
+    }
+    rango := bson.M{}
+    if valor := c.Query("registrado_desde"); valor != "" {
+        if desde, ok := parsearFechaFiltro(valor, false); ok {
+            rango["$gte"] = primitive.NewObjectIDFromTimestamp(desde)
+        } else {
+            errores.agregar("registrado_desde", "Fecha inválida (formato YYYY-MM-DD o RFC 3339)")
+        }
+    }
+    if valor := c.Query("registrado_hasta"); valor != "" {
+        if hasta, ok := parsearFechaFiltro(valor, true); ok {
+            rango["$lt"] = primitive.NewObjectIDFromTimestamp(hasta)
+        } else {
+            errores.agregar("registrado_hasta", "Fecha inválida (formato YYYY-MM-DD o RFC 3339)")
+        }
+    }
+    if len(rango) > 0 {
+        filter["_id"] = rango
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    cursor, err := col.Find(ctx, bson.M{})
+    res, err := utils.Paginar(ctx, getCollectionUsers(), filter, pagina, claveUsuario(pagina.Campo))
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al listar usuarios"})
     }
-    defer cursor.Close(ctx)
+    return c.JSON(respuestaPagina(c, models.NewUserResponses(res.Items, models.VistaAdmin), res, pagina))
+}
 
-    var users []models.User
-    if err := cursor.All(ctx, &users); err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al leer usuarios"})
+// claveUsuario regresa el valor del campo de orden de un usuario, para armar los cursores
+func claveUsuario(campo string) func(models.User) (interface{}, primitive.ObjectID) {
+    return func(u models.User) (interface{}, primitive.ObjectID) {
+        switch campo {
+        case "nombre":
+            return u.Nombre, u.ID
+        case "apellidos":
+            return u.Apellidos, u.ID
+        case "email":
+            return u.Email, u.ID
+        }
+        return nil, u.ID
     }
+}
 
-    return c.JSON(users)
+// crearIndicesListadoUsuarios crea los índices para ordenar el listado de usuarios, el _id va
+// al final porque es el desempate de la paginación
+func crearIndicesListadoUsuarios(ctx context.Context) error {
+    _, err := getCollectionUsers().Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "nombre", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("nombre_id")},
+        {Keys: bson.D{{Key: "apellidos", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("apellidos_id")},
+        {Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("email_id")},
+    })
+    return err
+}
+
+// vistaUsuario decide que vista le toca al usuario autenticado cuando consulta la cuenta idHex
+func vistaUsuario(c *fiber.Ctx, idHex string) models.VistaUsuario {
+    if rol, _ := c.Locals("rol").(string); rol == models.RolAdmin {
+        return models.VistaAdmin
+    }
+    if userID, _ := c.Locals("userID").(string); userID == idHex {
+        return models.VistaPropia
+    }
+    return models.VistaPublica
 }
 
-// GetUser obtiene un usuario por ID (parámetro URL)
+// GetMe regresa la cuenta del usuario autenticado
+func GetMe(c *fiber.Ctx) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    return c.JSON(models.NewUserResponse(user, models.VistaPropia))
+}
+
+// GetUser obtiene un usuario por ID (parámetro URL). Los usuarios normales solo ven el
+// perfil público de otras cuentas, la información completa es para la propia cuenta y admins
 func GetUser(c *fiber.Ctx) error {
     idParam := c.Params("id")
     objectID, err := primitive.ObjectIDFromHex(idParam)
@@ -149,14 +320,81 @@ func GetUser(c *fiber.Ctx) error {
     defer cancel()
 
     var user models.User
-    err = col.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+    err = col.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&user)
     if err != nil {
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
     }
-    return c.JSON(user)
+    return c.JSON(models.NewUserResponse(user, vistaUsuario(c, idParam)))
+}
+
+// UserUpdateRequest son los campos que un usuario puede editar de su cuenta. Todos son
+// opcionales, solo se actualizan los que vienen en el body
+type UserUpdateRequest struct {
+    Nombre          *string `json:"nombre"`
+    Apellidos       *string `json:"apellidos"`
+    Email           *string `json:"email"`
+    FechaNacimiento *string `json:"fecha_nacimiento"` // "2006-01-02"
+    PreferenciasRequest
+}
+
+// camposEditablesUsuario es la lista blanca de campos que acepta UpdateUser
+var camposEditablesUsuario = []string{"nombre", "apellidos", "email", "fecha_nacimiento", "zona_horaria", "idioma", "inicio_semana"}
+
+// longitudMaximaNombre es el máximo de caracteres de nombre y apellidos
+const longitudMaximaNombre = 100
+
+// validar revisa cada campo y regresa el $set listo para Mongo junto con los errores por campo
+func (r UserUpdateRequest) validar() (bson.M, erroresCampo) {
+    set := bson.M{}
+    errores := erroresCampo{}
+
+    if r.Nombre != nil {
+        nombre := strings.TrimSpace(*r.Nombre)
+        switch {
+        case nombre == "":
+            errores.agregar("nombre", "El nombre no puede estar vacío")
+        case len([]rune(nombre)) > longitudMaximaNombre:
+            errores.agregar("nombre", "El nombre es demasiado largo")
+        default:
+            set["nombre"] = nombre
+        }
+    }
+    if r.Apellidos != nil {
+        apellidos := strings.TrimSpace(*r.Apellidos)
+        if len([]rune(apellidos)) > longitudMaximaNombre {
+            errores.agregar("apellidos", "Los apellidos son demasiado largos")
+        } else {
+            set["apellidos"] = apellidos
+        }
+    }
+    if r.Email != nil {
+        email := normalizarEmail(*r.Email)
+        switch {
+        case !emailValido(email):
+            errores.agregar("email", "Email inválido")
+        case !dominioEmailPermitido(email):
+            errores.agregar("email", "El dominio del email no está permitido")
+        default:
+            set["email"] = email
+        }
+    }
+    if r.FechaNacimiento != nil {
+        fecha, problema := parsearFechaNacimiento(*r.FechaNacimiento)
+        switch {
+        case problema != "":
+            errores.agregar("fecha_nacimiento", problema)
+        case !edadSuficiente(fecha):
+            errores.agregar("fecha_nacimiento", "La fecha no cumple la edad mínima de registro")
+        default:
+            set["fecha_nacimiento"] = fecha
+        }
+    }
+    setPreferencias(set, r.PreferenciasRequest.validar(errores))
+    return set, errores
 }
 
-// UpdateUser actualiza datos (excepto contraseña)
+// UpdateUser actualiza los datos de perfil (nombre, apellidos, email, fecha de nacimiento y preferencias).
+// La contraseña, el rol y los datos de seguridad tienen sus propias rutas
 func UpdateUser(c *fiber.Ctx) error {
     idParam := c.Params("id")
     objectID, err := primitive.ObjectIDFromHex(idParam)
@@ -164,29 +402,151 @@ func UpdateUser(c *fiber.Ctx) error {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
     }
 
-    var updates map[string]interface{}
-    if err := c.BodyParser(&updates); err != nil {
+    var body UserUpdateRequest
+    if err := c.BodyParser(&body); err != nil {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
     }
 
-    // qui se prohibimos cambiar el campo "password" desde aquí
-    delete(updates, "password")
+    // Cualquier campo fuera de la lista blanca es un error, no se ignora en silencio
+    errores := erroresCampo{}
+    extra, err := camposNoPermitidos(c.Body(), camposEditablesUsuario...)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    for _, campo := range extra {
+        errores.agregar(campo, "El campo no se puede modificar")
+    }
+
+    set, erroresValidacion := body.validar()
+    for campo, mensaje := range erroresValidacion {
+        errores.agregar(campo, mensaje)
+    }
+    if len(errores) > 0 {
+        return responderValidacion(c, errores)
+    }
+    if len(set) == 0 {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No hay campos para actualizar"})
+    }
 
     col := getCollectionUsers()
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
-    result, err := col.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": updates})
+    var actual models.User
+    if err := col.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&actual); err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+
+    // Si cambia el email hay que volver a verificarlo, que no lo tenga otra cuenta lo revisa el índice
+    // único o, mientras falte, emailOcupado
+    email, cambiaEmail := set["email"].(string)
+    cambiaEmail = cambiaEmail && email != actual.Email
+    if cambiaEmail {
+        ocupado, err := emailOcupado(ctx, email, objectID)
+        if err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al revisar el email"})
+        }
+        if ocupado {
+            return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Datos inválidos", "campos": erroresCampo{"email": "Email ya registrado"}})
+        }
+    }
+    if cambiaEmail && config.EmailVerificacion != config.VerificacionOff {
+        set["email_verificado"] = false
+    }
+
+    result, err := col.UpdateOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}, bson.M{"$set": set})
+    if mongo.IsDuplicateKeyError(err) {
+        return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Datos inválidos", "campos": erroresCampo{"email": "Email ya registrado"}})
+    }
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar el usuario"})
     }
     if result.MatchedCount == 0 {
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
     }
+
+    if cambiaEmail && config.EmailVerificacion != config.VerificacionOff {
+        // Los tokens ya emitidos toman el email sin verificar de la sesión, no de su claim
+        utils.OlvidarSesion(objectID.Hex())
+        actual.Email = email
+        if err := enviarVerificacion(ctx, actual); err != nil {
+            log.Println("Error al enviar verificación a", email, ":", err)
+        }
+    }
     return c.JSON(fiber.Map{"message": "Usuario actualizado exitosamente"})
 }
 
-// DeleteUser elimina un usuario por ID
+// ChangePassword cambia la contraseña del usuario autenticado. Pide la contraseña actual,
+// aplica la política de contraseñas y cierra todas las sesiones anteriores; la respuesta trae
+// un par de tokens nuevo para que el cliente que hizo el cambio no pierda la sesión
+func ChangePassword(c *fiber.Ctx) error {
+    type Request struct {
+        PasswordActual string `json:"password_actual"`
+        NuevoPassword  string `json:"nuevo_password"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+    if err := utils.ValidarPassword(body.NuevoPassword); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+    }
+    if body.NuevoPassword == body.PasswordActual {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "La contraseña nueva debe ser distinta a la actual"})
+    }
+
+    col := getCollectionUsers()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    // Con un token robado no se debe poder adivinar la contraseña actual sin límite
+    clave := "password:" + c.Locals("userID").(string)
+    espera, err := reservarIntento(ctx, clave, config.LoginMaxFallos)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+    if espera > 0 {
+        return responderBloqueo(c, espera)
+    }
+
+    user, err := usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.PasswordActual)) != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Contraseña actual incorrecta"})
+    }
+    if err := limpiarFallos(ctx, clave); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NuevoPassword), bcrypt.DefaultCost)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al encriptar contraseña"})
+    }
+    if _, err := col.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"password": string(hashedPassword)}}); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo actualizar la contraseña"})
+    }
+
+    if err := cerrarSesiones(ctx, user.ID); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudieron cerrar las sesiones"})
+    }
+
+    // se vuelve a leer el usuario para firmar con la versión de tokens nueva
+    user, err = usuarioActual(ctx, c)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+    }
+    tokens, err := emitirTokens(ctx, user, primitive.NewObjectID())
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al generar token"})
+    }
+    tokens["message"] = "Contraseña actualizada exitosamente"
+    return c.JSON(tokens)
+}
+
+// DeleteUser elimina un usuario por ID. El borrado es lógico: se marca deleted_at en la cuenta
+// y en sus tasks, se cierran sus sesiones y se borra para siempre al pasar el periodo de gracia
 func DeleteUser(c *fiber.Ctx) error {
     idParam := c.Params("id")
     objectID, err := primitive.ObjectIDFromHex(idParam)
@@ -194,16 +554,31 @@ func DeleteUser(c *fiber.Ctx) error {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID inválido"})
     }
 
-    col := getCollectionUsers()
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    result, err := col.DeleteOne(ctx, bson.M{"_id": objectID})
+    encontrado, err := eliminarUsuario(ctx, objectID)
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo eliminar el usuario"})
     }
-    if result.DeletedCount == 0 {
+    if !encontrado {
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
     }
     return c.JSON(fiber.Map{"message": "Usuario eliminado exitosamente"})
 }
+
+// usuarioActual carga el usuario autenticado (el userID que dejó el middleware JWT)
+func usuarioActual(ctx context.Context, c *fiber.Ctx) (models.User, error) {
+    return buscarUsuarioPorID(ctx, c.Locals("userID").(string))
+}
+
+// buscarUsuarioPorID carga un usuario a partir de su ID en hexadecimal
+func buscarUsuarioPorID(ctx context.Context, idHex string) (models.User, error) {
+    var user models.User
+    objectID, err := primitive.ObjectIDFromHex(idHex)
+    if err != nil {
+        return user, err
+    }
+    err = getCollectionUsers().FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&user)
+    return user, err
+}
@@ -0,0 +1,66 @@
+// handlers/validation.go
+package handlers
+
+import (
+    "encoding/json"
+    "net/mail"
+    "sort"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+)
+
+// erroresCampo junta los errores de validación por campo para regresarlos todos juntos
+type erroresCampo map[string]string
+
+// agregar guarda el error del campo, si el campo ya tenía uno se conserva el primero
+func (e erroresCampo) agregar(campo, mensaje string) {
+    if _, existe := e[campo]; !existe {
+        e[campo] = mensaje
+    }
+}
+
+// responderValidacion contesta 400 con el formato común de errores de validación:
+// {"error": "Datos inválidos", "campos": {"campo": "motivo", ...}}
+func responderValidacion(c *fiber.Ctx, errores erroresCampo) error {
+    return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos", "campos": errores})
+}
+
+// camposNoPermitidos regresa las llaves del JSON que no están en la lista de campos editables
+func camposNoPermitidos(body []byte, permitidos ...string) ([]string, error) {
+    var crudo map[string]json.RawMessage
+    if err := json.Unmarshal(body, &crudo); err != nil {
+        return nil, err
+    }
+    lista := map[string]bool{}
+    for _, campo := range permitidos {
+        lista[campo] = true
+    }
+
+    var extra []string
+    for campo := range crudo {
+        if !lista[campo] {
+            extra = append(extra, campo)
+        }
+    }
+    sort.Strings(extra)
+    return extra, nil
+}
+
+// emailValido acepta solo la dirección, sin nombre ("Juan <juan@x.com>" no se acepta)
+func emailValido(email string) bool {
+    direccion, err := mail.ParseAddress(email)
+    return err == nil && direccion.Address == email
+}
+
+// parsearFechaNacimiento lee la fecha en formato YYYY-MM-DD y revisa que no sea futura
+func parsearFechaNacimiento(valor string) (time.Time, string) {
+    fecha, err := time.Parse("2006-01-02", valor)
+    if err != nil {
+        return fecha, "Fecha de nacimiento inválida (formato YYYY-MM-DD)"
+    }
+    if fecha.After(time.Now()) {
+        return fecha, "La fecha de nacimiento no puede ser futura"
+    }
+    return fecha, ""
+}
@@ -0,0 +1,105 @@
+// handlers/verification_handler.go
+package handlers
+
+import (
+    "context"
+    "net/url"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "go.mongodb.org/mongo-driver/bson"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/mailer"
+    "github.com/ImanolCE/api-rest-go/models"
+    "github.com/ImanolCE/api-rest-go/utils"
+)
+
+// enviarVerificacion manda el correo con el enlace firmado para verificar el email
+func enviarVerificacion(ctx context.Context, user models.User) error {
+    token, err := utils.GenerarTokenVerificacion(user)
+    if err != nil {
+        return err
+    }
+    enlace := config.AppURL + "/api/verify-email?token=" + url.QueryEscape(token)
+
+    return mailer.Enviar(ctx, mailer.Mensaje{
+        Para:   user.Email,
+        Asunto: "Verifica tu email",
+        Cuerpo: "Hola " + user.Nombre + ",\n\n" +
+            "Para verificar tu email abre el siguiente enlace, es válido por 24 horas:\n\n" +
+            enlace + "\n\nSi no creaste una cuenta puedes ignorar este correo.",
+    })
+}
+
+// VerifyEmail marca el email como verificado si el token del enlace es válido.
+// El token puede venir en la query (?token=) o en el body
+func VerifyEmail(c *fiber.Ctx) error {
+    type Request struct {
+        Token string `json:"token"`
+    }
+    body := Request{Token: c.Query("token")}
+    if body.Token == "" {
+        if err := c.BodyParser(&body); err != nil || body.Token == "" {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Falta el token de verificación"})
+        }
+    }
+
+    claims, err := utils.ValidarTokenTipo(body.Token, utils.TipoVerificacionEmail)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Enlace de verificación inválido o expirado"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    user, err := buscarUsuarioPorID(ctx, claims.UserID)
+    if err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    // si el usuario cambió de email el enlace viejo ya no sirve
+    if user.Email != claims.Email {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Enlace de verificación inválido o expirado"})
+    }
+    if user.EmailVerificado {
+        return c.JSON(fiber.Map{"message": "El email ya estaba verificado"})
+    }
+
+    update := bson.M{"$set": bson.M{"email_verificado": true, "email_verificado_en": time.Now()}}
+    if _, err := getCollectionUsers().UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo verificar el email"})
+    }
+    utils.OlvidarSesion(user.ID.Hex())
+    return c.JSON(fiber.Map{"message": "Email verificado exitosamente"})
+}
+
+// ResendVerification vuelve a mandar el correo de verificación. Siempre responde lo mismo
+// para no revelar qué emails están registrados, y los envíos tienen el mismo límite que el login
+func ResendVerification(c *fiber.Ctx) error {
+    type Request struct {
+        Email string `json:"email"`
+    }
+    var body Request
+    if err := c.BodyParser(&body); err != nil || body.Email == "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Datos inválidos"})
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    clave := "verify:" + claveEmail(body.Email)
+    espera, err := reservarIntento(ctx, clave, config.LoginMaxFallos)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error al verificar intentos"})
+    }
+    if espera > 0 {
+        return responderBloqueo(c, espera)
+    }
+
+    if user, err := buscarUsuarioPorEmail(ctx, body.Email); err == nil && !user.EmailVerificado {
+        if err := enviarVerificacion(ctx, user); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No se pudo enviar el correo"})
+        }
+    }
+    return c.JSON(fiber.Map{"message": "Si el email está registrado y sin verificar se envió un correo nuevo"})
+}
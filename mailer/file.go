@@ -0,0 +1,37 @@
+// mailer/file.go
+package mailer
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// FileMailer guarda cada correo como un archivo .eml, sirve para desarrollo sin servidor SMTP
+type FileMailer struct {
+    Dir string
+}
+
+// Enviar implementa Mailer
+func (m *FileMailer) Enviar(ctx context.Context, msg Mensaje) error {
+    nombre := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+    return os.WriteFile(filepath.Join(m.Dir, nombre), formatoRFC822(msg), 0o644)
+}
+
+// ConsoleMailer imprime los correos en la salida indicada (normalmente stdout)
+type ConsoleMailer struct {
+    Salida io.Writer
+    mu     sync.Mutex
+}
+
+// Enviar implementa Mailer
+func (m *ConsoleMailer) Enviar(ctx context.Context, msg Mensaje) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _, err := fmt.Fprintf(m.Salida, "---- correo para %s ----\nAsunto: %s\n\n%s\n--------\n", msg.Para, msg.Asunto, msg.Cuerpo)
+    return err
+}
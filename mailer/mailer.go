@@ -0,0 +1,70 @@
+// mailer/mailer.go
+package mailer
+
+import (
+    "context"
+    "fmt"
+    "os"
+
+    "github.com/ImanolCE/api-rest-go/config"
+)
+
+// Mensaje es un correo de texto plano
+type Mensaje struct {
+    De     string
+    Para   string
+    Asunto string
+    Cuerpo string
+}
+
+// Mailer es cualquier forma de enviar correos, asi se puede cambiar SMTP por un archivo
+// o por memoria para probar sin servidor de correo
+type Mailer interface {
+    Enviar(ctx context.Context, msg Mensaje) error
+}
+
+// Default es el mailer que usan los handlers, se configura al arrancar con Configurar
+var Default Mailer = &ConsoleMailer{Salida: os.Stdout}
+
+// Configurar crea el mailer según config.MailerTipo y lo deja en Default
+func Configurar() error {
+    switch config.MailerTipo {
+    case "smtp":
+        Default = &SMTPMailer{
+            Host:     config.SMTPHost,
+            Puerto:   config.SMTPPuerto,
+            Usuario:  config.SMTPUsuario,
+            Password: config.SMTPPassword,
+        }
+    case "file":
+        if err := os.MkdirAll(config.MailerDir, 0o755); err != nil {
+            return err
+        }
+        Default = &FileMailer{Dir: config.MailerDir}
+    case "console":
+        Default = &ConsoleMailer{Salida: os.Stdout}
+    case "memory":
+        Default = &MemoryMailer{}
+    default:
+        return fmt.Errorf("MAILER desconocido %q", config.MailerTipo)
+    }
+    return nil
+}
+
+// Enviar manda el mensaje con el mailer por defecto, si no trae remitente usa config.MailRemitente
+func Enviar(ctx context.Context, msg Mensaje) error {
+    if msg.De == "" {
+        msg.De = config.MailRemitente
+    }
+    return Default.Enviar(ctx, msg)
+}
+
+// formatoRFC822 arma el correo con sus headers, lo usan el SMTP y el de archivo
+func formatoRFC822(msg Mensaje) []byte {
+    return []byte("From: " + msg.De + "\r\n" +
+        "To: " + msg.Para + "\r\n" +
+        "Subject: " + msg.Asunto + "\r\n" +
+        "MIME-Version: 1.0\r\n" +
+        "Content-Type: text/plain; charset=UTF-8\r\n" +
+        "\r\n" + msg.Cuerpo + "\r\n")
+}
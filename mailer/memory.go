@@ -0,0 +1,35 @@
+// mailer/memory.go
+package mailer
+
+import (
+    "context"
+    "sync"
+)
+
+// MemoryMailer guarda los correos en memoria, para pruebas sin red
+type MemoryMailer struct {
+    mu       sync.Mutex
+    enviados []Mensaje
+}
+
+// Enviar implementa Mailer
+func (m *MemoryMailer) Enviar(ctx context.Context, msg Mensaje) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.enviados = append(m.enviados, msg)
+    return nil
+}
+
+// Enviados regresa una copia de los correos enviados hasta ahora
+func (m *MemoryMailer) Enviados() []Mensaje {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return append([]Mensaje(nil), m.enviados...)
+}
+
+// Limpiar borra los correos guardados
+func (m *MemoryMailer) Limpiar() {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.enviados = nil
+}
@@ -0,0 +1,38 @@
+// mailer/smtp.go
+package mailer
+
+import (
+    "context"
+    "net"
+    "net/smtp"
+    "strconv"
+)
+
+// SMTPMailer envía los correos por un servidor SMTP (con STARTTLS si el servidor lo ofrece)
+type SMTPMailer struct {
+    Host     string
+    Puerto   int
+    Usuario  string
+    Password string
+}
+
+// Enviar implementa Mailer
+func (m *SMTPMailer) Enviar(ctx context.Context, msg Mensaje) error {
+    var auth smtp.Auth
+    if m.Usuario != "" {
+        auth = smtp.PlainAuth("", m.Usuario, m.Password, m.Host)
+    }
+    addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Puerto))
+
+    // net/smtp no recibe context, se respeta al menos la cancelación antes de enviar
+    errCh := make(chan error, 1)
+    go func() {
+        errCh <- smtp.SendMail(addr, auth, msg.De, []string{msg.Para}, formatoRFC822(msg))
+    }()
+    select {
+    case err := <-errCh:
+        return err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
@@ -24,14 +24,29 @@ import (
 package main
 
 import (
+    "log"
+
     "github.com/gofiber/fiber/v2"
     "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/handlers"
+    "github.com/ImanolCE/api-rest-go/mailer"
     "github.com/ImanolCE/api-rest-go/routes"
+    "github.com/ImanolCE/api-rest-go/utils"
 )
 
 func main() {
-    // 1. Conectar a BD
+    // 1. Cargar las llaves para firmar JWT, configurar el correo y conectar a BD
+    if err := utils.CargarLlaves(); err != nil {
+        log.Fatal("Error al cargar llaves JWT: ", err)
+    }
+    if err := mailer.Configurar(); err != nil {
+        log.Fatal("Error al configurar el mailer: ", err)
+    }
     config.ConnectDB()
+    handlers.CrearIndices()
+    handlers.EjecutarMigraciones()
+    handlers.BootstrapAdmin()
+    handlers.IniciarPurga()
 
     // 2. Crear instancia de Fiber
     app := fiber.New()
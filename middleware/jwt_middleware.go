@@ -2,8 +2,13 @@
 package middleware
 
 import (
+    "context"
+    "strings"
+    "time"
+
     "github.com/gofiber/fiber/v2"
    // "github.com/golang-jwt/jwt/v4"
+    "github.com/ImanolCE/api-rest-go/models"
     "github.com/ImanolCE/api-rest-go/utils"
 )
 
@@ -27,7 +32,81 @@ func JWTMiddleware(c *fiber.Ctx) error {
     if err != nil {
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token inválido: " + err.Error()})
     }
+
+    // Se revisa que el token no haya sido revocado (logout), que el usuario no haya cerrado todas sus
+    // sesiones y que la cuenta no esté suspendida
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    revocado, err := utils.TokenRevocado(ctx, claims.ID)
+    if err != nil {
+        return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "No se pudo validar la sesión"})
+    }
+    if revocado {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token revocado"})
+    }
+    sesion, err := utils.EstadoSesion(ctx, claims.UserID)
+    if err != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if !models.CuentaActiva(sesion.Estado) {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": models.MensajeEstado(sesion.Estado)})
+    }
+    if claims.Version != sesion.Version {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token revocado"})
+    }
+
     // Puedes pasar el UserID en locals para usarlo en el handler
     c.Locals("userID", claims.UserID)
+    c.Locals("rol", claims.Rol)
+    c.Locals("emailVerificado", sesion.EmailVerificado)
+    c.Locals("claims", claims)
+    return c.Next()
+}
+
+// AuthMiddleware acepta un JWT o un token de acceso personal ("pat_..."). Solo se usa en las
+// rutas que declaran el scope que necesitan con RequireScope, el resto sigue siendo solo JWT
+func AuthMiddleware(c *fiber.Ctx) error {
+    tokenString := ExtractToken(c)
+    if !strings.HasPrefix(tokenString, utils.PrefijoAccessToken) {
+        return JWTMiddleware(c)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    pat, err := utils.ValidarAccessToken(ctx, tokenString)
+    if err != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token inválido: " + err.Error()})
+    }
+    sesion, err := utils.EstadoSesion(ctx, pat.UsuarioID.Hex())
+    if err != nil {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Usuario no encontrado"})
+    }
+    if !models.CuentaActiva(sesion.Estado) {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": models.MensajeEstado(sesion.Estado)})
+    }
+
+    // Un token personal nunca tiene permisos de administrador, solo los de sus scopes
+    c.Locals("userID", pat.UsuarioID.Hex())
+    c.Locals("rol", models.RolUsuario)
+    c.Locals("emailVerificado", sesion.EmailVerificado)
+    c.Locals("scopes", pat.Scopes)
     return c.Next()
 }
+
+// RequireScope exige el scope al token personal, las peticiones con JWT tienen todos los scopes
+func RequireScope(scope string) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        scopes, esPAT := c.Locals("scopes").([]string)
+        if !esPAT {
+            return c.Next()
+        }
+        for _, s := range scopes {
+            if s == scope {
+                return c.Next()
+            }
+        }
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "El token no tiene el scope " + scope})
+    }
+}
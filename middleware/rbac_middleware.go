@@ -0,0 +1,55 @@
+// middleware/rbac_middleware.go
+package middleware
+
+import (
+    "github.com/gofiber/fiber/v2"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// esAdmin indica si el usuario autenticado tiene rol de administrador
+func esAdmin(c *fiber.Ctx) bool {
+    rol, _ := c.Locals("rol").(string)
+    return rol == models.RolAdmin
+}
+
+// RequireRole solo deja pasar a usuarios con alguno de los roles indicados, va después de JWTMiddleware
+func RequireRole(roles ...string) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        rol, _ := c.Locals("rol").(string)
+        if rol == "" {
+            rol = models.RolUsuario
+        }
+        for _, permitido := range roles {
+            if rol == permitido {
+                return c.Next()
+            }
+        }
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "No tienes permiso para realizar esta acción"})
+    }
+}
+
+// SelfOrAdmin solo deja pasar si el parámetro de la URL es el propio usuario o si es administrador
+func SelfOrAdmin(param string) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        userID, _ := c.Locals("userID").(string)
+        if c.Params(param) == userID || esAdmin(c) {
+            return c.Next()
+        }
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "No tienes permiso para acceder a este usuario"})
+    }
+}
+
+// RequireVerifiedEmail bloquea la ruta a las cuentas sin email verificado cuando la política es
+// "restrict". El dato sale de la sesión del usuario y no del claim del JWT, así un cambio de email
+// aplica también a los tokens ya emitidos y a los tokens personales
+func RequireVerifiedEmail(c *fiber.Ctx) error {
+    if config.EmailVerificacion != config.VerificacionRestringe {
+        return c.Next()
+    }
+    if verificado, ok := c.Locals("emailVerificado").(bool); ok && !verificado {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Debes verificar tu email para realizar esta acción"})
+    }
+    return c.Next()
+}
@@ -0,0 +1,31 @@
+// models/access_token.go
+package models
+
+import (
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "time"
+)
+
+// Scopes que puede tener un token de acceso personal
+const (
+    ScopeTasksRead  = "tasks:read"
+    ScopeTasksWrite = "tasks:write"
+)
+
+// ScopesValidos es la lista de scopes que se pueden pedir al crear un token
+var ScopesValidos = []string{ScopeTasksRead, ScopeTasksWrite}
+
+// coleccion de tokens de acceso personal (para scripts y cuentas de servicio),
+// se guarda el hash del token y un prefijo visible para que el usuario lo reconozca
+type AccessToken struct {
+    ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+    UsuarioID primitive.ObjectID `json:"usuario_id" bson:"usuario_id"`
+    Nombre    string             `json:"nombre" bson:"nombre"`
+    Prefijo   string             `json:"prefijo" bson:"prefijo"`
+    TokenHash string             `json:"-" bson:"token_hash"`
+    Scopes    []string           `json:"scopes" bson:"scopes"`
+    CreadoEn  time.Time          `json:"creado_en" bson:"creado_en"`
+    ExpiraEn  *time.Time         `json:"expira_en,omitempty" bson:"expira_en,omitempty"`
+    UltimoUso *time.Time         `json:"ultimo_uso,omitempty" bson:"ultimo_uso,omitempty"`
+    Revocado  bool               `json:"revocado" bson:"revocado"`
+}
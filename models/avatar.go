@@ -0,0 +1,22 @@
+// models/avatar.go
+package models
+
+import (
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "time"
+)
+
+// AvatarTamanos son los tamaños (en pixeles, cuadrados) que se generan de cada avatar
+var AvatarTamanos = []int{64, 128, 256}
+
+// AvatarTamanoDefault es el tamaño que se entrega si no se pide uno
+const AvatarTamanoDefault = 128
+
+// Avatar es la foto de perfil del usuario. Cada tamaño es un archivo en GridFS (bucket "avatars"),
+// Version cambia con cada subida y sirve para que los navegadores no usen una copia vieja
+type Avatar struct {
+    Version       primitive.ObjectID            `json:"-" bson:"version"`
+    ContentType   string                        `json:"-" bson:"content_type"`
+    Archivos      map[string]primitive.ObjectID `json:"-" bson:"archivos"` // tamaño -> archivo en GridFS
+    ActualizadoEn time.Time                     `json:"-" bson:"actualizado_en"`
+}
@@ -0,0 +1,34 @@
+// models/checklist.go
+package models
+
+import (
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "time"
+)
+
+// MaxItemsChecklist es el máximo de elementos del checklist de una task
+const MaxItemsChecklist = 100
+
+// ItemChecklist es un elemento del checklist de una task. El orden del checklist es el orden de la lista
+type ItemChecklist struct {
+    ID      primitive.ObjectID `json:"id" bson:"_id"`
+    Texto   string             `json:"texto" bson:"texto"`
+    Hecho   bool               `json:"hecho" bson:"hecho"`
+    HechoEn *time.Time         `json:"hecho_en,omitempty" bson:"hecho_en,omitempty"`
+}
+
+// ProgresoChecklist regresa el porcentaje (0 a 100, redondeado hacia abajo) de elementos hechos,
+// nil si el checklist está vacío
+func ProgresoChecklist(items []ItemChecklist) *int {
+    if len(items) == 0 {
+        return nil
+    }
+    hechos := 0
+    for _, item := range items {
+        if item.Hecho {
+            hechos++
+        }
+    }
+    progreso := hechos * 100 / len(items)
+    return &progreso
+}
@@ -0,0 +1,28 @@
+// models/export_job.go
+package models
+
+import (
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "time"
+)
+
+// Estados de una exportación de datos
+const (
+    ExportPendiente = "pendiente"
+    ExportListo     = "listo"
+    ExportError     = "error"
+)
+
+// coleccion de exportaciones de datos personales, el zip se guarda en GridFS (bucket "exports")
+// y se borra junto con el trabajo cuando pasa ExpiraEn
+type ExportJob struct {
+    ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+    UsuarioID   primitive.ObjectID  `json:"-" bson:"usuario_id"`
+    Estado      string              `json:"estado" bson:"estado"`
+    ArchivoID   *primitive.ObjectID `json:"-" bson:"archivo_id,omitempty"`
+    Tamano      int64               `json:"tamano,omitempty" bson:"tamano,omitempty"`
+    Error       string              `json:"error,omitempty" bson:"error,omitempty"`
+    CreadoEn    time.Time           `json:"creado_en" bson:"creado_en"`
+    TerminadoEn *time.Time          `json:"terminado_en,omitempty" bson:"terminado_en,omitempty"`
+    ExpiraEn    time.Time           `json:"expira_en" bson:"expira_en"`
+}
@@ -0,0 +1,22 @@
+// models/invitation.go
+package models
+
+import (
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "time"
+)
+
+// coleccion de códigos de invitación para el registro. Solo se guarda el hash del código,
+// el prefijo sirve para que el admin lo reconozca en la lista
+type Invitacion struct {
+    ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+    Prefijo    string             `json:"prefijo" bson:"prefijo"`
+    CodigoHash string             `json:"-" bson:"codigo_hash"`
+    Email      string             `json:"email,omitempty" bson:"email,omitempty"` // si viene, solo ese email la puede usar
+    UsosMax    int                `json:"usos_max" bson:"usos_max"`
+    Usos       int                `json:"usos" bson:"usos"`
+    CreadoPor  primitive.ObjectID `json:"creado_por" bson:"creado_por"`
+    CreadoEn   time.Time          `json:"creado_en" bson:"creado_en"`
+    ExpiraEn   *time.Time         `json:"expira_en,omitempty" bson:"expira_en,omitempty"`
+    Revocada   bool               `json:"revocada" bson:"revocada"`
+}
@@ -0,0 +1,13 @@
+// models/login_attempt.go
+package models
+
+import "time"
+
+// coleccion de intentos de login fallidos, el ID es "email:<email>" o "ip:<ip>"
+type LoginAttempt struct {
+    ID             string     `json:"id" bson:"_id"`
+    Fallos         int        `json:"fallos" bson:"fallos"`
+    UltimoFallo    time.Time  `json:"ultimo_fallo" bson:"ultimo_fallo"`
+    BloqueadoHasta *time.Time `json:"bloqueado_hasta,omitempty" bson:"bloqueado_hasta,omitempty"`
+    ExpiraEn       time.Time  `json:"expira_en" bson:"expira_en"`
+}
@@ -0,0 +1,56 @@
+// models/preferences.go
+package models
+
+import "time"
+
+// Valores de las preferencias cuando el usuario no ha elegido
+const (
+    ZonaHorariaDefault  = "UTC"
+    IdiomaDefault       = "es"
+    InicioSemanaDefault = "lunes"
+)
+
+// DiasInicioSemana son los días con los que puede empezar la semana del usuario
+var DiasInicioSemana = map[string]time.Weekday{
+    "domingo": time.Sunday,
+    "lunes":   time.Monday,
+    "sabado":  time.Saturday,
+}
+
+// Preferencias son los ajustes de fecha e idioma del usuario. La zona horaria se usa para leer
+// las fechas sin offset y para las consultas por día o semana
+type Preferencias struct {
+    ZonaHoraria  string `json:"zona_horaria" bson:"zona_horaria,omitempty"`   // nombre IANA, "America/Mexico_City"
+    Idioma       string `json:"idioma" bson:"idioma,omitempty"`               // etiqueta BCP 47, "es-MX"
+    InicioSemana string `json:"inicio_semana" bson:"inicio_semana,omitempty"` // "lunes", "domingo" o "sabado"
+}
+
+// ConDefaults llena las preferencias que el usuario no ha elegido
+func (p Preferencias) ConDefaults() Preferencias {
+    if p.ZonaHoraria == "" {
+        p.ZonaHoraria = ZonaHorariaDefault
+    }
+    if p.Idioma == "" {
+        p.Idioma = IdiomaDefault
+    }
+    if p.InicioSemana == "" {
+        p.InicioSemana = InicioSemanaDefault
+    }
+    return p
+}
+
+// Ubicacion regresa la zona horaria del usuario, UTC si no tiene o no se puede cargar
+func (p Preferencias) Ubicacion() *time.Location {
+    if loc, err := time.LoadLocation(p.ConDefaults().ZonaHoraria); err == nil {
+        return loc
+    }
+    return time.UTC
+}
+
+// DiaInicioSemana regresa el día con el que empieza la semana del usuario
+func (p Preferencias) DiaInicioSemana() time.Weekday {
+    if dia, ok := DiasInicioSemana[p.ConDefaults().InicioSemana]; ok {
+        return dia
+    }
+    return time.Monday
+}
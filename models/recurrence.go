@@ -0,0 +1,37 @@
+// models/recurrence.go
+package models
+
+import "time"
+
+// Alcances de un cambio sobre una ocurrencia de una serie
+const (
+    AlcanceEsta       = "esta"       // solo esa ocurrencia
+    AlcanceSiguientes = "siguientes" // esa ocurrencia y las que siguen
+)
+
+// Recurrencia es la regla de repetición de una task. La task es la primera ocurrencia de la serie
+// y las demás se calculan al consultar una ventana de fechas, no se guardan
+type Recurrencia struct {
+    Regla       string      `json:"rrule" bson:"rrule"`                                   // subconjunto de RRULE (RFC 5545), "FREQ=WEEKLY;BYDAY=MO"
+    ZonaHoraria string      `json:"zona_horaria" bson:"zona_horaria"`                     // zona en la que se cuentan los días y la hora
+    Excepciones []time.Time `json:"excepciones,omitempty" bson:"excepciones,omitempty"` // inicios de ocurrencias que se quitaron o se editaron aparte
+    Fin         *time.Time  `json:"fin,omitempty" bson:"fin,omitempty"`                 // fin de la última ocurrencia, vacío si la serie no termina
+}
+
+// Ubicacion regresa la zona de la serie, UTC si no es válida
+func (r Recurrencia) Ubicacion() *time.Location {
+    if loc, err := time.LoadLocation(r.ZonaHoraria); err == nil {
+        return loc
+    }
+    return time.UTC
+}
+
+// EsExcepcion indica si la ocurrencia que empieza en inicio se quitó de la serie
+func (r Recurrencia) EsExcepcion(inicio time.Time) bool {
+    for _, e := range r.Excepciones {
+        if e.Equal(inicio) {
+            return true
+        }
+    }
+    return false
+}
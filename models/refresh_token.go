@@ -0,0 +1,20 @@
+// models/refresh_token.go
+package models
+
+import (
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "time"
+)
+
+// coleccion de refresh tokens, solo se guarda el hash del token nunca el valor en claro.
+// Todos los tokens que salen de un mismo login comparten FamiliaID
+type RefreshToken struct {
+    ID          primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+    UsuarioID   primitive.ObjectID  `json:"usuario_id" bson:"usuario_id"`
+    FamiliaID   primitive.ObjectID  `json:"familia_id" bson:"familia_id"`
+    TokenHash   string              `json:"-" bson:"token_hash"`
+    CreadoEn    time.Time           `json:"creado_en" bson:"creado_en"`
+    ExpiraEn    time.Time           `json:"expira_en" bson:"expira_en"`
+    UsadoEn     *time.Time          `json:"usado_en,omitempty" bson:"usado_en,omitempty"`
+    Revocado    bool                `json:"revocado" bson:"revocado"`
+}
@@ -6,6 +6,69 @@ import (
     "time"
 )
 
+// Estados de una task. Completada y cancelada son estados finales, de ahí solo se sale reabriendo la task
+const (
+    EstadoTaskPendiente  = "pendiente"
+    EstadoTaskEnProgreso = "en_progreso"
+    EstadoTaskCompletada = "completada"
+    EstadoTaskCancelada  = "cancelada"
+)
+
+// TransicionesTask son los cambios de estado permitidos, de cada estado a los que puede pasar
+var TransicionesTask = map[string][]string{
+    EstadoTaskPendiente:  {EstadoTaskEnProgreso, EstadoTaskCompletada, EstadoTaskCancelada},
+    EstadoTaskEnProgreso: {EstadoTaskPendiente, EstadoTaskCompletada, EstadoTaskCancelada},
+    EstadoTaskCompletada: {EstadoTaskPendiente, EstadoTaskEnProgreso},
+    EstadoTaskCancelada:  {EstadoTaskPendiente},
+}
+
+// EstadoTaskValido indica si estado es uno de los estados de una task
+func EstadoTaskValido(estado string) bool {
+    _, ok := TransicionesTask[estado]
+    return ok
+}
+
+// TransicionTaskValida indica si una task puede pasar de desde a hacia
+func TransicionTaskValida(desde, hacia string) bool {
+    for _, e := range TransicionesTask[desde] {
+        if e == hacia {
+            return true
+        }
+    }
+    return false
+}
+
+// OrigenesTransicion regresa los estados desde los que se puede llegar a hacia
+func OrigenesTransicion(hacia string) []string {
+    var origenes []string
+    for desde := range TransicionesTask {
+        if TransicionTaskValida(desde, hacia) {
+            origenes = append(origenes, desde)
+        }
+    }
+    return origenes
+}
+
+// Prioridades de una task, de menor a mayor
+const (
+    PrioridadBaja    = "baja"
+    PrioridadMedia   = "media"
+    PrioridadAlta    = "alta"
+    PrioridadUrgente = "urgente"
+)
+
+// PrioridadDefault es la prioridad de las tasks que no indican una
+const PrioridadDefault = PrioridadMedia
+
+// PrioridadValida indica si prioridad es una de las prioridades de una task
+func PrioridadValida(prioridad string) bool {
+    switch prioridad {
+    case PrioridadBaja, PrioridadMedia, PrioridadAlta, PrioridadUrgente:
+        return true
+    }
+    return false
+}
+
 // coleccion de task
 type Task struct {
     ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
@@ -13,5 +76,13 @@ type Task struct {
     Descripcion string           `json:"descripcion" bson:"descripcion"`
     FechaInicio  time.Time       `json:"fecha_inicio" bson:"fecha_inicio"`
     FechaFinal     time.Time       `json:"fecha_final" bson:"fecha_final"`
+    Estado       string            `json:"estado" bson:"estado"`
+    Prioridad    string            `json:"prioridad" bson:"prioridad"`
+    CompletadaEn *time.Time        `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+    Recurrencia  *Recurrencia      `json:"recurrencia,omitempty" bson:"recurrencia,omitempty"`
+    Checklist    []ItemChecklist   `json:"checklist,omitempty" bson:"checklist,omitempty"`
+    SerieID      *primitive.ObjectID `json:"serie_id,omitempty" bson:"serie_id,omitempty"`     // en una ocurrencia editada aparte, la serie de la que salió
+    Ocurrencia   *time.Time         `json:"ocurrencia,omitempty" bson:"ocurrencia,omitempty"` // inicio original de esa ocurrencia en la serie
     UsuarioID    primitive.ObjectID `json:"usuario_id" bson:"usuario_id"`
+    EliminadoEn  *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
 }
@@ -0,0 +1,83 @@
+// models/task_response.go
+package models
+
+import "time"
+
+// TaskResponse es la task tal como se regresa en la API
+type TaskResponse struct {
+    ID          string    `json:"id"`
+    Titulo      string    `json:"titulo"`
+    Descripcion string    `json:"descripcion"`
+    FechaInicio time.Time `json:"fecha_inicio"`
+    FechaFinal  time.Time `json:"fecha_final"`
+    Estado      string     `json:"estado"`
+    Prioridad   string     `json:"prioridad"`
+    CompletadaEn *time.Time `json:"completed_at"`
+    Recurrencia *Recurrencia `json:"recurrencia,omitempty"`
+    SerieID     string       `json:"serie_id,omitempty"`
+    Ocurrencia  *time.Time   `json:"ocurrencia,omitempty"`
+    Checklist   []ItemChecklist `json:"checklist"`
+    Progreso    *int            `json:"progreso,omitempty"` // porcentaje del checklist hecho
+    UsuarioID   string    `json:"usuario_id"`
+}
+
+// NewTaskResponse arma la respuesta de una task
+func NewTaskResponse(t Task) TaskResponse {
+    r := TaskResponse{
+        ID:          t.ID.Hex(),
+        Titulo:      t.Titulo,
+        Descripcion: t.Descripcion,
+        FechaInicio: t.FechaInicio,
+        FechaFinal:  t.FechaFinal,
+        Estado:      t.Estado,
+        Prioridad:   t.Prioridad,
+        CompletadaEn: t.CompletadaEn,
+        Recurrencia: t.Recurrencia,
+        Ocurrencia:  t.Ocurrencia,
+        Checklist:   t.Checklist,
+        Progreso:    ProgresoChecklist(t.Checklist),
+        UsuarioID:   t.UsuarioID.Hex(),
+    }
+    if r.Checklist == nil {
+        r.Checklist = []ItemChecklist{}
+    }
+    if t.SerieID != nil {
+        r.SerieID = t.SerieID.Hex()
+    }
+    return r
+}
+
+// NewOcurrenciaResponse arma la respuesta de una ocurrencia calculada de la serie t que empieza en
+// inicio. Tiene el id de la serie, con ese id y "ocurrencia" se edita o completa solo esa
+func NewOcurrenciaResponse(t Task, inicio time.Time) TaskResponse {
+    r := NewTaskResponse(t)
+    r.FechaFinal = inicio.Add(t.FechaFinal.Sub(t.FechaInicio))
+    r.FechaInicio = inicio
+    r.SerieID = t.ID.Hex()
+    r.Ocurrencia = &inicio
+    return r
+}
+
+// EnZona regresa la respuesta con las fechas expresadas en loc, el instante es el mismo
+func (r TaskResponse) EnZona(loc *time.Location) TaskResponse {
+    r.FechaInicio = r.FechaInicio.In(loc)
+    r.FechaFinal = r.FechaFinal.In(loc)
+    if r.CompletadaEn != nil {
+        completada := r.CompletadaEn.In(loc)
+        r.CompletadaEn = &completada
+    }
+    if r.Ocurrencia != nil {
+        ocurrencia := r.Ocurrencia.In(loc)
+        r.Ocurrencia = &ocurrencia
+    }
+    return r
+}
+
+// NewTaskResponses convierte una lista de tasks
+func NewTaskResponses(tasks []Task) []TaskResponse {
+    lista := make([]TaskResponse, len(tasks))
+    for i, t := range tasks {
+        lista[i] = NewTaskResponse(t)
+    }
+    return lista
+}
@@ -6,14 +6,76 @@ import (
     "time"
 )
 
+// Roles de usuario
+const (
+    RolAdmin   = "admin"
+    RolUsuario = "user"
+)
+
+// Estados de la cuenta. Una cuenta suspendida (abuso) o bloqueada (por ejemplo comprometida) solo
+// la reactiva un admin, una bloqueada tampoco puede recuperar su contraseña con la pregunta secreta
+const (
+    EstadoActivo     = "active"
+    EstadoSuspendido = "suspended"
+    EstadoBloqueado  = "locked"
+)
+
+// CuentaActiva indica si el estado permite iniciar sesión y usar tokens. Las cuentas creadas
+// antes de que existiera el estado no tienen el campo y están activas
+func CuentaActiva(estado string) bool {
+    return estado == "" || estado == EstadoActivo
+}
+
+// MensajeEstado es el error que se le muestra a una cuenta que no está activa
+func MensajeEstado(estado string) string {
+    if estado == EstadoBloqueado {
+        return "La cuenta está bloqueada, contacta a soporte"
+    }
+    return "La cuenta está suspendida"
+}
+
+// CambioEstado es un registro del historial de estados de la cuenta
+type CambioEstado struct {
+    Estado  string             `json:"status" bson:"status"`
+    Motivo  string             `json:"motivo" bson:"motivo"`
+    AdminID *primitive.ObjectID `json:"admin_id,omitempty" bson:"admin_id,omitempty"` // vacío si lo hizo el propio usuario
+    Fecha   time.Time          `json:"fecha" bson:"fecha"`
+}
+
 // coleccion del usuario
 type User struct {
     ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
     Nombre      string             `json:"nombre" bson:"nombre"`
     Apellidos    string             `json:"apellidos" bson:"apellidos"`
     Email         string             `json:"email" bson:"email"`
-    Password        string             `json:"password" bson:"password"`
+    EmailVerificado   bool           `json:"email_verificado" bson:"email_verificado"`
+    EmailVerificadoEn *time.Time     `json:"email_verificado_en,omitempty" bson:"email_verificado_en,omitempty"`
+    Password        string             `json:"-" bson:"password"`
     FechaNacimiento  time.Time          `json:"fecha_nacimiento" bson:"fecha_nacimiento"`
     PreguntaSecreta  string             `json:"pregunta_secreta" bson:"pregunta_secreta"`
-    RespuestaSecreta string             `json:"respuesta_secreta" bson:"respuesta_secreta"`
+    RespuestaSecreta string             `json:"-" bson:"respuesta_secreta"` // hash bcrypt de la respuesta normalizada
+    Rol              string             `json:"rol" bson:"rol"`
+    TokenVersion     int                `json:"-" bson:"token_version"`
+    Estado           string             `json:"status" bson:"status"`
+    HistorialEstado  []CambioEstado     `json:"-" bson:"historial_estado,omitempty"`
+    IntentosRecuperacion  int                `json:"-" bson:"intentos_recuperacion"`
+    RecuperacionBloqueada *time.Time         `json:"-" bson:"recuperacion_bloqueada,omitempty"`
+    TOTPActivo            bool               `json:"totp_activo" bson:"totp_activo"`
+    TOTPSecreto           string             `json:"-" bson:"totp_secreto,omitempty"`
+    TOTPPendiente         string             `json:"-" bson:"totp_pendiente,omitempty"`
+    TOTPUltimoPaso        int64              `json:"-" bson:"totp_ultimo_paso,omitempty"`
+    CodigosRecuperacion   []string           `json:"-" bson:"codigos_recuperacion,omitempty"` // hashes SHA-256
+    EliminadoEn           *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+    Avatar                *Avatar            `json:"-" bson:"avatar,omitempty"`
+    Preferencias          Preferencias       `json:"preferencias" bson:"preferencias"`
+    InvitacionID          *primitive.ObjectID `json:"-" bson:"invitacion_id,omitempty"`
+}
+
+// AvatarURL es la ruta para descargar la foto de perfil, vacía si el usuario no tiene.
+// Lleva la versión para que al cambiar la foto cambie la URL y no se use la copia en caché
+func (u User) AvatarURL() string {
+    if u.Avatar == nil {
+        return ""
+    }
+    return "/api/users/" + u.ID.Hex() + "/avatar?v=" + u.Avatar.Version.Hex()
 }
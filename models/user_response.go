@@ -0,0 +1,88 @@
+// models/user_response.go
+package models
+
+import "time"
+
+// VistaUsuario indica cuanta información del usuario se puede mostrar
+type VistaUsuario int
+
+const (
+    // VistaPublica es el perfil mínimo (nombre, apellidos y avatar), es el valor por defecto para
+    // que una vista sin decidir nunca muestre de más
+    VistaPublica VistaUsuario = iota
+    // VistaPropia es lo que ve el usuario de su propia cuenta
+    VistaPropia
+    // VistaAdmin es la vista extendida para administradores
+    VistaAdmin
+)
+
+// UserResponse es el usuario tal como se regresa en la API. Nunca lleva la contraseña,
+// la respuesta secreta ni los secretos de 2FA; los campos vacíos dependen de la vista
+type UserResponse struct {
+    ID                    string     `json:"id"`
+    Nombre                string     `json:"nombre"`
+    Apellidos             string     `json:"apellidos"`
+    AvatarURL             string     `json:"avatar_url,omitempty"`
+    Email                 string     `json:"email,omitempty"`
+    FechaNacimiento       *time.Time `json:"fecha_nacimiento,omitempty"`
+    PreguntaSecreta       string     `json:"pregunta_secreta,omitempty"`
+    Rol                   string     `json:"rol,omitempty"`
+    Estado                string     `json:"status,omitempty"`
+    EmailVerificado       *bool      `json:"email_verificado,omitempty"`
+    TOTPActivo            *bool      `json:"totp_activo,omitempty"`
+    Preferencias          *Preferencias `json:"preferencias,omitempty"`
+    EmailVerificadoEn     *time.Time `json:"email_verificado_en,omitempty"`
+    FechaRegistro         *time.Time `json:"fecha_registro,omitempty"`
+    RecuperacionBloqueada *time.Time `json:"recuperacion_bloqueada,omitempty"`
+    EliminadoEn           *time.Time `json:"deleted_at,omitempty"`
+    HistorialEstado       []CambioEstado `json:"historial_estado,omitempty"`
+}
+
+// NewUserResponse arma la respuesta del usuario según la vista
+func NewUserResponse(u User, vista VistaUsuario) UserResponse {
+    r := UserResponse{
+        ID:        u.ID.Hex(),
+        Nombre:    u.Nombre,
+        Apellidos: u.Apellidos,
+        AvatarURL: u.AvatarURL(),
+    }
+    if vista == VistaPublica {
+        return r
+    }
+
+    fechaNacimiento := u.FechaNacimiento
+    emailVerificado := u.EmailVerificado
+    totpActivo := u.TOTPActivo
+    r.Email = u.Email
+    r.FechaNacimiento = &fechaNacimiento
+    r.PreguntaSecreta = u.PreguntaSecreta
+    r.Rol = u.Rol
+    r.Estado = u.Estado
+    if r.Estado == "" {
+        r.Estado = EstadoActivo
+    }
+    r.EmailVerificado = &emailVerificado
+    r.TOTPActivo = &totpActivo
+    preferencias := u.Preferencias.ConDefaults()
+    r.Preferencias = &preferencias
+    if vista == VistaPropia {
+        return r
+    }
+
+    registro := u.ID.Timestamp()
+    r.FechaRegistro = &registro
+    r.EmailVerificadoEn = u.EmailVerificadoEn
+    r.RecuperacionBloqueada = u.RecuperacionBloqueada
+    r.EliminadoEn = u.EliminadoEn
+    r.HistorialEstado = u.HistorialEstado
+    return r
+}
+
+// NewUserResponses convierte una lista de usuarios con la misma vista
+func NewUserResponses(users []User, vista VistaUsuario) []UserResponse {
+    lista := make([]UserResponse, len(users))
+    for i, u := range users {
+        lista[i] = NewUserResponse(u, vista)
+    }
+    return lista
+}
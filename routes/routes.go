@@ -5,26 +5,89 @@ import (
     "github.com/gofiber/fiber/v2"
     "github.com/ImanolCE/api-rest-go/handlers"
     "github.com/ImanolCE/api-rest-go/middleware"
+    "github.com/ImanolCE/api-rest-go/models"
 )
 
 func Setup(app *fiber.App) {
     // Rutas públicas
+    app.Get("/.well-known/jwks.json", handlers.GetJWKS)
     app.Post("/api/register", handlers.RegisterUser)
     app.Post("/api/login", handlers.LoginUser)
+    app.Post("/api/login/2fa", handlers.LoginTOTP)
+    app.Post("/api/token/refresh", handlers.RefreshToken)
+
+    // Los avatares son públicos para poder usarlos directo en un <img>
+    app.Get("/api/users/:id/avatar", handlers.GetAvatar)
+
+    // Recuperación de contraseña con la pregunta secreta
+    app.Post("/api/recovery/question", handlers.GetRecoveryQuestion)
+    app.Post("/api/recovery/reset", handlers.ResetPassword)
+
+    // Verificación de email
+    app.Get("/api/verify-email", handlers.VerifyEmail)
+    app.Post("/api/verify-email", handlers.VerifyEmail)
+    app.Post("/api/verify-email/resend", handlers.ResendVerification)
+
+    // CRUD Tasks, aceptan JWT o token de acceso personal con el scope de cada ruta. Se registran
+    // antes del grupo /api para que no pasen por JWTMiddleware, que solo acepta JWT
+    tasks := app.Group("/api/tasks", middleware.AuthMiddleware)
+    tasks.Post("/", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.CreateTask)
+    tasks.Get("/", middleware.RequireScope(models.ScopeTasksRead), handlers.GetTasks)
+    tasks.Get("/agenda", middleware.RequireScope(models.ScopeTasksRead), handlers.GetAgenda)
+    tasks.Get("/:id", middleware.RequireScope(models.ScopeTasksRead), handlers.GetTask)
+    tasks.Put("/:id", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.UpdateTask)
+    tasks.Delete("/:id", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.DeleteTask)
+    tasks.Post("/:id/complete", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.CompleteTask)
+    tasks.Post("/:id/reopen", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.ReopenTask)
+    tasks.Post("/:id/checklist", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.AddChecklistItem)
+    tasks.Put("/:id/checklist", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.ReorderChecklist)
+    tasks.Post("/:id/checklist/:itemId/toggle", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.ToggleChecklistItem)
+    tasks.Delete("/:id/checklist/:itemId", middleware.RequireScope(models.ScopeTasksWrite), middleware.RequireVerifiedEmail, handlers.DeleteChecklistItem)
 
     // Rutas protegidas son las que requieren token JWT
     api := app.Group("/api", middleware.JWTMiddleware)
+
+    // Sesiones
+    api.Post("/logout", handlers.Logout)
+    api.Post("/logout/all", handlers.LogoutAll)
+
+    // Cuenta propia, van antes de /users/:id para que "me" no se tome como ID
+    api.Get("/users/me", handlers.GetMe)
+    api.Put("/users/me/password", handlers.ChangePassword)
+
+    // Exportación de datos personales
+    api.Post("/users/me/export", handlers.ExportMe)
+    api.Get("/users/me/export/:id", handlers.GetExport)
+    api.Get("/users/me/export/:id/download", handlers.DownloadExport)
+
+    // Verificación en dos pasos (TOTP)
+    api.Post("/users/me/2fa/setup", handlers.SetupTOTP)
+    api.Post("/users/me/2fa/confirm", handlers.ConfirmTOTP)
+    api.Post("/users/me/2fa/disable", handlers.DisableTOTP)
+    api.Post("/users/me/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+
+    // Tokens de acceso personal
+    api.Post("/users/me/tokens", middleware.RequireVerifiedEmail, handlers.CreateAccessToken)
+    api.Get("/users/me/tokens", handlers.GetAccessTokens)
+    api.Delete("/users/me/tokens/:id", handlers.RevokeAccessToken)
 	
-    // CRUD Usuarios GET/UPDATE/DELETE
-    api.Get("/users", handlers.GetUsers)
-    api.Get("/users/:id", handlers.GetUser)
-    api.Put("/users/:id", handlers.UpdateUser)
-    api.Delete("/users/:id", handlers.DeleteUser)
-
-    // CRUD Tasks
-    api.Post("/tasks", handlers.CreateTask)
-    api.Get("/tasks", handlers.GetTasks)
-    api.Get("/tasks/:id", handlers.GetTask)
-    api.Put("/tasks/:id", handlers.UpdateTask)
-    api.Delete("/tasks/:id", handlers.DeleteTask)
+    // CRUD Usuarios GET/UPDATE/DELETE, cada usuario solo puede ver y modificar su cuenta salvo los admins
+    api.Get("/users", middleware.RequireRole(models.RolAdmin), handlers.GetUsers)
+    api.Get("/users/:id", middleware.SelfOrAdmin("id"), handlers.GetUser)
+    api.Put("/users/:id", middleware.SelfOrAdmin("id"), handlers.UpdateUser)
+    api.Delete("/users/:id", middleware.SelfOrAdmin("id"), handlers.DeleteUser)
+    api.Put("/users/:id/avatar", middleware.SelfOrAdmin("id"), handlers.UploadAvatar)
+    api.Delete("/users/:id/avatar", middleware.SelfOrAdmin("id"), handlers.DeleteAvatar)
+
+    // Administración
+    admin := api.Group("/admin", middleware.RequireRole(models.RolAdmin))
+    admin.Put("/users/:id/role", handlers.SetUserRole)
+    admin.Delete("/users/:id/lock", handlers.UnlockUser)
+    admin.Post("/users/:id/suspend", handlers.SuspendUser)
+    admin.Post("/users/:id/reactivate", handlers.ReactivateUser)
+    admin.Post("/users/:id/restore", handlers.RestoreUser)
+    admin.Get("/users/duplicate-emails", handlers.GetDuplicateEmails)
+    admin.Post("/invitations", handlers.CreateInvitation)
+    admin.Get("/invitations", handlers.GetInvitations)
+    admin.Delete("/invitations/:id", handlers.RevokeInvitation)
 }
@@ -0,0 +1,60 @@
+// utils/access_tokens.go
+package utils
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "github.com/ImanolCE/api-rest-go/config"
+    "github.com/ImanolCE/api-rest-go/models"
+)
+
+// PrefijoAccessToken distingue a los tokens personales de los JWT en el header Authorization
+const PrefijoAccessToken = "pat_"
+
+// largoPrefijoVisible es cuantos caracteres del token se guardan en claro para mostrarlos
+const largoPrefijoVisible = len(PrefijoAccessToken) + 8
+
+// GenerarAccessToken crea un token personal, regresa el token completo (solo se muestra una vez),
+// el prefijo visible y el hash que se guarda
+func GenerarAccessToken() (string, string, string, error) {
+    b := make([]byte, 30)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", "", err
+    }
+    token := PrefijoAccessToken + base64.RawURLEncoding.EncodeToString(b)
+    return token, token[:largoPrefijoVisible], HashToken(token), nil
+}
+
+// ValidarAccessToken busca el token personal por su hash y revisa que no esté revocado ni expirado
+func ValidarAccessToken(ctx context.Context, token string) (*models.AccessToken, error) {
+    col := config.ClientMongo.Database(config.DBName).Collection("access_tokens")
+
+    var pat models.AccessToken
+    err := col.FindOne(ctx, bson.M{"token_hash": HashToken(token)}).Decode(&pat)
+    if err == mongo.ErrNoDocuments {
+        return nil, errors.New("token desconocido")
+    }
+    if err != nil {
+        return nil, err
+    }
+    if pat.Revocado {
+        return nil, errors.New("token revocado")
+    }
+    ahora := time.Now()
+    if pat.ExpiraEn != nil && ahora.After(*pat.ExpiraEn) {
+        return nil, errors.New("token expirado")
+    }
+
+    // el último uso se actualiza como mucho una vez por minuto para no escribir en cada petición
+    if pat.UltimoUso == nil || ahora.Sub(*pat.UltimoUso) > time.Minute {
+        col.UpdateOne(ctx, bson.M{"_id": pat.ID}, bson.M{"$set": bson.M{"ultimo_uso": ahora}})
+    }
+    return &pat, nil
+}
@@ -0,0 +1,53 @@
+// utils/fechas.go
+package utils
+
+import (
+    "errors"
+    "time"
+
+    // Incluye la base de zonas horarias en el binario, así funciona aunque el sistema no la tenga
+    _ "time/tzdata"
+)
+
+// formatosFechaLocal son los formatos sin offset que se aceptan, se leen en la zona del usuario
+var formatosFechaLocal = []string{
+    "2006-01-02T15:04:05",
+    "2006-01-02T15:04",
+    "2006-01-02 15:04:05",
+    "2006-01-02 15:04",
+    "2006-01-02",
+}
+
+// ErrFechaInvalida se regresa cuando la fecha no tiene ninguno de los formatos aceptados
+var ErrFechaInvalida = errors.New("fecha inválida")
+
+// ParsearFechaLocal lee una fecha RFC 3339 (con offset) o una fecha local sin offset, que se
+// interpreta en loc. Una fecha sin hora es el inicio de ese día. Siempre regresa la fecha en UTC
+func ParsearFechaLocal(valor string, loc *time.Location) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, valor); err == nil {
+        return t.UTC(), nil
+    }
+    for _, formato := range formatosFechaLocal {
+        if t, err := time.ParseInLocation(formato, valor, loc); err == nil {
+            return t.UTC(), nil
+        }
+    }
+    return time.Time{}, ErrFechaInvalida
+}
+
+// RangoDia regresa el inicio y el fin (exclusivo) del día local que contiene t. Con horario de
+// verano un día puede durar 23 o 25 horas, por eso se calcula con la fecha y no sumando 24h
+func RangoDia(t time.Time, loc *time.Location) (time.Time, time.Time) {
+    t = t.In(loc)
+    inicio := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+    return inicio.UTC(), inicio.AddDate(0, 0, 1).UTC()
+}
+
+// RangoSemana regresa el inicio y el fin (exclusivo) de la semana local que contiene t, la
+// semana empieza en el día inicioSemana
+func RangoSemana(t time.Time, loc *time.Location, inicioSemana time.Weekday) (time.Time, time.Time) {
+    t = t.In(loc)
+    atras := (int(t.Weekday()) - int(inicioSemana) + 7) % 7
+    inicio := time.Date(t.Year(), t.Month(), t.Day()-atras, 0, 0, 0, 0, loc)
+    return inicio.UTC(), inicio.AddDate(0, 0, 7).UTC()
+}
@@ -0,0 +1,32 @@
+// utils/invitaciones.go
+package utils
+
+import (
+    "crypto/rand"
+    "encoding/base32"
+    "strings"
+)
+
+// PrefijoInvitacion identifica a los códigos de invitación
+const PrefijoInvitacion = "inv_"
+
+// GenerarCodigoInvitacion crea un código de invitación, regresa el código completo (solo se
+// muestra una vez), el prefijo visible y el hash que se guarda. Se usa base32 para que se
+// pueda dictar o copiar a mano sin confundir caracteres
+func GenerarCodigoInvitacion() (string, string, string, error) {
+    b := make([]byte, 10)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", "", err
+    }
+    codigo := PrefijoInvitacion + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+    return codigo, codigo[:len(PrefijoInvitacion)+4], HashCodigoInvitacion(codigo), nil
+}
+
+// HashCodigoInvitacion normaliza el código (mayúsculas, sin espacios) y lo hashea
+func HashCodigoInvitacion(codigo string) string {
+    codigo = strings.TrimSpace(codigo)
+    if len(codigo) > len(PrefijoInvitacion) && strings.EqualFold(codigo[:len(PrefijoInvitacion)], PrefijoInvitacion) {
+        codigo = PrefijoInvitacion + strings.ToUpper(codigo[len(PrefijoInvitacion):])
+    }
+    return HashToken(codigo)
+}
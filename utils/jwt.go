@@ -2,43 +2,143 @@
 package utils
 
 import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
     "time"
 
     "github.com/golang-jwt/jwt/v4"
+
+    "github.com/ImanolCE/api-rest-go/models"
 )
 
-var jwtSecret = []byte("SECRETO_UTEQ2") 
+// DuracionAccessToken es la vigencia del JWT de acceso
+const DuracionAccessToken = 10 * time.Minute
+
+// DuracionRefreshToken es la vigencia de cada refresh token, se renueva en cada rotación
+const DuracionRefreshToken = 30 * 24 * time.Hour
+
+// DuracionTokenMFA es la vigencia del token intermedio entre la contraseña y el código 2FA
+const DuracionTokenMFA = 5 * time.Minute
+
+// DuracionTokenVerificacion es la vigencia del enlace para verificar el email
+const DuracionTokenVerificacion = 24 * time.Hour
+
+// Tipos de token, el middleware solo acepta TipoAcceso y cada flujo valida el suyo
+const (
+    TipoAcceso            = "access"
+    TipoMFA               = "mfa_pending"
+    TipoVerificacionEmail = "email_verify"
+)
 
-// ClaimsPersonalizados hereda de jwt.RegisteredClaims para agregar campos extras
+// ClaimsPersonalizados hereda de jwt.RegisteredClaims para agregar campos extras.
+// Version es la versión de tokens del usuario, si el usuario cierra todas sus sesiones
+// la versión aumenta y los tokens anteriores dejan de ser válidos
 type ClaimsPersonalizados struct {
-    UserID string `json:"user_id"`
+    UserID          string `json:"user_id"`
+    Rol             string `json:"rol,omitempty"`
+    Version         int    `json:"ver"`
+    Tipo            string `json:"typ"`
+    EmailVerificado bool   `json:"email_verificado,omitempty"`
+    Email           string `json:"email,omitempty"`
     jwt.RegisteredClaims
 }
 
-// GenerarToken, es el qie genera un JWT para un userID, dado con expiración de 10 minutos
-func GenerarToken(userID string) (string, error) {
-    expira := time.Now().Add(10 * time.Minute)
+// GenerarToken, es el qie genera un JWT para un usuario, dado con expiración de 10 minutos.
+// Cada token lleva un jti único para poder revocarlo de forma individual
+func GenerarToken(user models.User) (string, error) {
+    return generarTokenTipo(&ClaimsPersonalizados{
+        UserID:          user.ID.Hex(),
+        Rol:             user.Rol,
+        Version:         user.TokenVersion,
+        Tipo:            TipoAcceso,
+        EmailVerificado: user.EmailVerificado,
+    }, DuracionAccessToken)
+}
+
+// GenerarTokenMFA genera el token "mfa pendiente" que se entrega cuando la contraseña es
+// correcta pero falta el código 2FA, no sirve para acceder a las rutas protegidas
+func GenerarTokenMFA(user models.User) (string, error) {
+    return generarTokenTipo(&ClaimsPersonalizados{
+        UserID:  user.ID.Hex(),
+        Version: user.TokenVersion,
+        Tipo:    TipoMFA,
+    }, DuracionTokenMFA)
+}
+
+// GenerarTokenVerificacion genera el token del enlace de verificación de email, lleva el
+// email para que el enlace deje de servir si el usuario cambia de email
+func GenerarTokenVerificacion(user models.User) (string, error) {
+    return generarTokenTipo(&ClaimsPersonalizados{
+        UserID: user.ID.Hex(),
+        Tipo:   TipoVerificacionEmail,
+        Email:  user.Email,
+    }, DuracionTokenVerificacion)
+}
+
+// generarTokenTipo completa los claims registrados (jti, iat, exp) y firma el token
+func generarTokenTipo(claims *ClaimsPersonalizados, duracion time.Duration) (string, error) {
+    ahora := time.Now()
 
-    claims := &ClaimsPersonalizados{
-        UserID: userID,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(expira),
-        },
+    jti, err := generarJTI()
+    if err != nil {
+        return "", err
     }
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString(jwtSecret)
+    claims.RegisteredClaims = jwt.RegisteredClaims{
+        ID:        jti,
+        IssuedAt:  jwt.NewNumericDate(ahora),
+        ExpiresAt: jwt.NewNumericDate(ahora.Add(duracion)),
+    }
+    return firmarToken(claims)
+}
+
+// generarJTI crea un identificador aleatorio para el claim "jti"
+func generarJTI() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
 }
 
-// se valida el token (ValidarToken) parsea y valida un token JWT
+// se valida el token (ValidarToken) parsea y valida un token JWT de acceso, solo se aceptan los
+// algoritmos del keyring (RS256/EdDSA) sin importar lo que diga el header del token
 func ValidarToken(tokenString string) (*ClaimsPersonalizados, error) {
+    return ValidarTokenTipo(tokenString, TipoAcceso)
+}
+
+// ValidarTokenTipo valida el token igual que ValidarToken pero exige el tipo indicado
+func ValidarTokenTipo(tokenString, tipo string) (*ClaimsPersonalizados, error) {
     claims := &ClaimsPersonalizados{}
-    token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-        return jwtSecret, nil
-    })
-    if err != nil || !token.Valid {
+    token, err := jwt.ParseWithClaims(tokenString, claims, buscarLlave, jwt.WithValidMethods(metodosPermitidos))
+    if err != nil {
         return nil, err
     }
+    if !token.Valid {
+        return nil, errors.New("token inválido")
+    }
+    if claims.Tipo != tipo {
+        return nil, errors.New("tipo de token incorrecto")
+    }
     return claims, nil
 }
 
+// GenerarRefreshToken crea un refresh token opaco y aleatorio, regresa el token
+// (que se entrega al cliente) y su hash (que es lo único que se guarda en la BD)
+func GenerarRefreshToken() (string, string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", err
+    }
+    token := base64.RawURLEncoding.EncodeToString(b)
+    return token, HashToken(token), nil
+}
+
+// HashToken calcula el SHA-256 de un token opaco para poder buscarlo en la BD
+func HashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
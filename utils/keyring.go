@@ -0,0 +1,212 @@
+// utils/keyring.go
+package utils
+
+import (
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "log"
+    "math/big"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/golang-jwt/jwt/v4"
+
+    "github.com/ImanolCE/api-rest-go/config"
+)
+
+// Llave es una llave del keyring identificada por su kid. Las llaves retiradas
+// no tienen parte privada y solo sirven para verificar tokens que siguen vigentes
+type Llave struct {
+    KID     string
+    Metodo  jwt.SigningMethod
+    Privada crypto.Signer
+    Publica crypto.PublicKey
+}
+
+// keyring contiene todas las llaves cargadas y la que se usa para firmar
+var keyring = struct {
+    llaves map[string]*Llave
+    activa *Llave
+}{llaves: map[string]*Llave{}}
+
+// metodosPermitidos son los únicos algoritmos que acepta ValidarToken
+var metodosPermitidos = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// CargarLlaves lee las llaves de config.JWTKeysDir. Si no hay ninguna llave privada regresa un
+// error, salvo con config.JWTKeysDev: entonces genera una Ed25519 temporal, sirve para desarrollo
+// pero los tokens no sobreviven un reinicio ni los acepta otra instancia
+func CargarLlaves() error {
+    llaves := map[string]*Llave{}
+
+    archivos, err := filepath.Glob(filepath.Join(config.JWTKeysDir, "*.pem"))
+    if err != nil {
+        return fmt.Errorf("JWT_KEYS_DIR inválido %q: %w", config.JWTKeysDir, err)
+    }
+    for _, archivo := range archivos {
+        kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(archivo), ".pem"), ".pub")
+        if _, existe := llaves[kid]; existe {
+            return fmt.Errorf("kid %q repetido en %s", kid, config.JWTKeysDir)
+        }
+        llave, err := leerLlave(kid, archivo)
+        if err != nil {
+            return fmt.Errorf("%s: %w", archivo, err)
+        }
+        llaves[kid] = llave
+    }
+
+    var activa *Llave
+    if config.JWTActiveKID != "" {
+        activa = llaves[config.JWTActiveKID]
+        if activa == nil || activa.Privada == nil {
+            return fmt.Errorf("no existe la llave privada activa %q", config.JWTActiveKID)
+        }
+    } else {
+        kids := make([]string, 0, len(llaves))
+        for kid, llave := range llaves {
+            if llave.Privada != nil {
+                kids = append(kids, kid)
+            }
+        }
+        sort.Strings(kids)
+        if len(kids) > 0 {
+            activa = llaves[kids[len(kids)-1]]
+        }
+    }
+
+    if activa == nil && !config.JWTKeysDev {
+        return fmt.Errorf("no hay llaves privadas en %s (JWT_KEYS_DEV=true genera una temporal para desarrollo)", config.JWTKeysDir)
+    }
+    if activa == nil {
+        publica, privada, err := ed25519.GenerateKey(rand.Reader)
+        if err != nil {
+            return err
+        }
+        b := make([]byte, 4)
+        if _, err := rand.Read(b); err != nil {
+            return err
+        }
+        activa = &Llave{KID: "temporal-" + hex.EncodeToString(b), Metodo: jwt.SigningMethodEdDSA, Privada: privada, Publica: publica}
+        llaves[activa.KID] = activa
+        log.Println("Aviso: no hay llaves en", config.JWTKeysDir, "se generó una llave temporal", activa.KID)
+    }
+
+    keyring.llaves = llaves
+    keyring.activa = activa
+    return nil
+}
+
+// leerLlave interpreta un archivo PEM con una llave privada (PKCS#8 o PKCS#1) o pública (PKIX)
+func leerLlave(kid, archivo string) (*Llave, error) {
+    data, err := os.ReadFile(archivo)
+    if err != nil {
+        return nil, err
+    }
+    bloque, _ := pem.Decode(data)
+    if bloque == nil {
+        return nil, errors.New("no es un archivo PEM válido")
+    }
+
+    var llave interface{}
+    switch bloque.Type {
+    case "PRIVATE KEY":
+        llave, err = x509.ParsePKCS8PrivateKey(bloque.Bytes)
+    case "RSA PRIVATE KEY":
+        llave, err = x509.ParsePKCS1PrivateKey(bloque.Bytes)
+    case "PUBLIC KEY":
+        llave, err = x509.ParsePKIXPublicKey(bloque.Bytes)
+    default:
+        return nil, fmt.Errorf("tipo de PEM no soportado %q", bloque.Type)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    switch k := llave.(type) {
+    case *rsa.PrivateKey:
+        if k.N.BitLen() < 2048 {
+            return nil, errors.New("las llaves RSA deben ser de al menos 2048 bits")
+        }
+        return &Llave{KID: kid, Metodo: jwt.SigningMethodRS256, Privada: k, Publica: &k.PublicKey}, nil
+    case *rsa.PublicKey:
+        return &Llave{KID: kid, Metodo: jwt.SigningMethodRS256, Publica: k}, nil
+    case ed25519.PrivateKey:
+        return &Llave{KID: kid, Metodo: jwt.SigningMethodEdDSA, Privada: k, Publica: k.Public()}, nil
+    case ed25519.PublicKey:
+        return &Llave{KID: kid, Metodo: jwt.SigningMethodEdDSA, Publica: k}, nil
+    }
+    return nil, errors.New("solo se soportan llaves RSA y Ed25519")
+}
+
+// firmarToken firma los claims con la llave activa y agrega el kid al header
+func firmarToken(claims jwt.Claims) (string, error) {
+    activa := keyring.activa
+    if activa == nil {
+        return "", errors.New("no hay llave activa para firmar")
+    }
+    token := jwt.NewWithClaims(activa.Metodo, claims)
+    token.Header["kid"] = activa.KID
+    return token.SignedString(activa.Privada)
+}
+
+// buscarLlave es el jwt.Keyfunc, busca la llave por kid y revisa que el algoritmo
+// del header sea el de esa llave, asi no se puede verificar una llave RSA como otro algoritmo
+func buscarLlave(token *jwt.Token) (interface{}, error) {
+    kid, _ := token.Header["kid"].(string)
+    llave := keyring.llaves[kid]
+    if llave == nil {
+        return nil, fmt.Errorf("kid desconocido %q", kid)
+    }
+    if token.Method.Alg() != llave.Metodo.Alg() {
+        return nil, fmt.Errorf("algoritmo %s no corresponde a la llave %q", token.Method.Alg(), kid)
+    }
+    return llave.Publica, nil
+}
+
+// JWK es una llave pública en formato JSON Web Key (RFC 7517)
+type JWK struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Alg string `json:"alg"`
+    N   string `json:"n,omitempty"`
+    E   string `json:"e,omitempty"`
+    Crv string `json:"crv,omitempty"`
+    X   string `json:"x,omitempty"`
+}
+
+// JWKS regresa las llaves públicas de todo el keyring, incluyendo las retiradas
+func JWKS() []JWK {
+    kids := make([]string, 0, len(keyring.llaves))
+    for kid := range keyring.llaves {
+        kids = append(kids, kid)
+    }
+    sort.Strings(kids)
+
+    jwks := make([]JWK, 0, len(kids))
+    for _, kid := range kids {
+        llave := keyring.llaves[kid]
+        jwk := JWK{Kid: kid, Use: "sig", Alg: llave.Metodo.Alg()}
+        switch pub := llave.Publica.(type) {
+        case *rsa.PublicKey:
+            jwk.Kty = "RSA"
+            jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+            jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+        case ed25519.PublicKey:
+            jwk.Kty = "OKP"
+            jwk.Crv = "Ed25519"
+            jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+        }
+        jwks = append(jwks, jwk)
+    }
+    return jwks
+}
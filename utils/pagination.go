@@ -0,0 +1,227 @@
+// utils/pagination.go
+package utils
+
+import (
+    "context"
+    "encoding/base64"
+    "errors"
+    "strings"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/bsontype"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Límites del tamaño de página
+const (
+    LimitePaginaDefault = 20
+    LimitePaginaMax     = 100
+)
+
+// ErrCursorInvalido se regresa cuando el cursor no se puede decodificar o no corresponde al orden pedido
+var ErrCursorInvalido = errors.New("cursor inválido")
+
+// Cursor es la posición dentro de un listado: el valor del campo de orden y el _id del último
+// (o primer) documento visto. Se manda al cliente como texto opaco
+type Cursor struct {
+    Orden    string             `bson:"o"`
+    Valor    interface{}        `bson:"v,omitempty"`
+    ID       primitive.ObjectID `bson:"id"`
+    Anterior bool               `bson:"p,omitempty"`
+}
+
+// CodificarCursor convierte el cursor en texto para la URL
+func CodificarCursor(c Cursor) string {
+    datos, err := bson.Marshal(c)
+    if err != nil {
+        return ""
+    }
+    return base64.RawURLEncoding.EncodeToString(datos)
+}
+
+// DecodificarCursor lee un cursor generado con CodificarCursor
+func DecodificarCursor(texto string) (Cursor, error) {
+    var c Cursor
+    datos, err := base64.RawURLEncoding.DecodeString(texto)
+    if err != nil {
+        return c, ErrCursorInvalido
+    }
+    if err := bson.Unmarshal(datos, &c); err != nil {
+        return c, ErrCursorInvalido
+    }
+    return c, nil
+}
+
+// CampoOrden es un orden permitido de un listado: el campo bson y el tipo que tiene su valor en
+// el cursor (bson.TypeString o bson.TypeDateTime). Al ordenar por _id Tipo va vacío porque el
+// cursor solo lleva el _id
+type CampoOrden struct {
+    Campo string
+    Tipo  bsontype.Type
+}
+
+// valorDeTipo indica si el valor decodificado de un cursor es un escalar del tipo esperado. El
+// cursor viene del cliente y el valor va directo al filtro, así un documento como {"$ne": null}
+// no se puede colar como operador
+func valorDeTipo(valor interface{}, tipo bsontype.Type) bool {
+    switch valor.(type) {
+    case nil:
+        return tipo == 0
+    case string:
+        return tipo == bson.TypeString
+    case primitive.DateTime:
+        return tipo == bson.TypeDateTime
+    }
+    return false
+}
+
+// Pagina describe que página de un listado se pide. Campo es el campo bson por el que se ordena,
+// siempre se desempata por _id para que el orden sea estable
+type Pagina struct {
+    Limite int
+    Orden  string // nombre público del orden, con "-" al inicio si es descendente
+    Campo  string
+    Desc   bool
+    Cursor *Cursor
+}
+
+// NuevaPagina valida los parámetros del listado. orden es el nombre público ("-nombre" para
+// descendente) y campos es la lista blanca de ordenes permitidos con su campo bson y el tipo de su valor
+func NuevaPagina(limite int, orden, cursor string, campos map[string]CampoOrden) (Pagina, error) {
+    p := Pagina{Limite: limite, Orden: orden}
+    if p.Limite <= 0 {
+        p.Limite = LimitePaginaDefault
+    }
+    if p.Limite > LimitePaginaMax {
+        p.Limite = LimitePaginaMax
+    }
+
+    nombre := strings.TrimPrefix(orden, "-")
+    campo, ok := campos[nombre]
+    if !ok {
+        return p, errors.New("orden no permitido")
+    }
+    p.Campo = campo.Campo
+    p.Desc = strings.HasPrefix(orden, "-")
+
+    if cursor != "" {
+        c, err := DecodificarCursor(cursor)
+        if err != nil {
+            return p, err
+        }
+        // Un cursor de otro orden apuntaría a cualquier lugar del listado
+        if c.Orden != orden || !valorDeTipo(c.Valor, campo.Tipo) {
+            return p, ErrCursorInvalido
+        }
+        p.Cursor = &c
+    }
+    return p, nil
+}
+
+// haciaAtras indica si la consulta recorre el listado al revés (al pedir la página anterior)
+func (p Pagina) haciaAtras() bool {
+    return p.Cursor != nil && p.Cursor.Anterior
+}
+
+// filtroCursor es la condición para quedarse con los documentos después (o antes) del cursor
+func (p Pagina) filtroCursor() bson.M {
+    if p.Cursor == nil {
+        return nil
+    }
+    op := "$gt"
+    if p.Desc != p.haciaAtras() {
+        op = "$lt"
+    }
+    if p.Campo == "_id" {
+        return bson.M{"_id": bson.M{op: p.Cursor.ID}}
+    }
+    return bson.M{"$or": bson.A{
+        bson.M{p.Campo: bson.M{op: p.Cursor.Valor}},
+        bson.M{p.Campo: p.Cursor.Valor, "_id": bson.M{op: p.Cursor.ID}},
+    }}
+}
+
+// orden regresa el sort de la consulta, invertido si se va hacia atrás
+func (p Pagina) orden() bson.D {
+    dir := 1
+    if p.Desc != p.haciaAtras() {
+        dir = -1
+    }
+    if p.Campo == "_id" {
+        return bson.D{{Key: "_id", Value: dir}}
+    }
+    return bson.D{{Key: p.Campo, Value: dir}, {Key: "_id", Value: dir}}
+}
+
+// Resultado es una página de documentos. Siguiente y Anterior son los cursores para las
+// páginas vecinas, vacíos si no hay más documentos en esa dirección
+type Resultado[T any] struct {
+    Items     []T
+    Total     int64
+    Siguiente string
+    Anterior  string
+}
+
+// Paginar consulta una página de col. filter son los filtros del listado (el total se cuenta con
+// ellos), clave regresa el valor del campo de orden y el _id de cada documento para armar los cursores
+func Paginar[T any](ctx context.Context, col *mongo.Collection, filter bson.M, p Pagina, clave func(T) (interface{}, primitive.ObjectID)) (Resultado[T], error) {
+    res := Resultado[T]{Items: []T{}}
+
+    total, err := col.CountDocuments(ctx, filter)
+    if err != nil {
+        return res, err
+    }
+    res.Total = total
+
+    consulta := filter
+    if c := p.filtroCursor(); c != nil {
+        consulta = bson.M{"$and": bson.A{filter, c}}
+    }
+    // Se pide uno de más para saber si hay otra página en esta dirección
+    opts := options.Find().SetSort(p.orden()).SetLimit(int64(p.Limite + 1))
+    cursor, err := col.Find(ctx, consulta, opts)
+    if err != nil {
+        return res, err
+    }
+    if err := cursor.All(ctx, &res.Items); err != nil {
+        return res, err
+    }
+
+    hayMas := len(res.Items) > p.Limite
+    if hayMas {
+        res.Items = res.Items[:p.Limite]
+    }
+    if p.haciaAtras() {
+        for i, j := 0, len(res.Items)-1; i < j; i, j = i+1, j-1 {
+            res.Items[i], res.Items[j] = res.Items[j], res.Items[i]
+        }
+    }
+    if len(res.Items) == 0 {
+        return res, nil
+    }
+
+    cursorDe := func(item T, anterior bool) string {
+        valor, id := clave(item)
+        if p.Campo == "_id" {
+            valor = nil
+        }
+        return CodificarCursor(Cursor{Orden: p.Orden, Valor: valor, ID: id, Anterior: anterior})
+    }
+    // Hacia adelante hay página anterior si se llegó con un cursor; hacia atrás siempre hay siguiente
+    if p.haciaAtras() {
+        res.Siguiente = cursorDe(res.Items[len(res.Items)-1], false)
+        if hayMas {
+            res.Anterior = cursorDe(res.Items[0], true)
+        }
+    } else {
+        if hayMas {
+            res.Siguiente = cursorDe(res.Items[len(res.Items)-1], false)
+        }
+        if p.Cursor != nil {
+            res.Anterior = cursorDe(res.Items[0], true)
+        }
+    }
+    return res, nil
+}
@@ -0,0 +1,82 @@
+// utils/password.go
+package utils
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "unicode"
+
+    "golang.org/x/crypto/bcrypt"
+    "golang.org/x/text/runes"
+    "golang.org/x/text/transform"
+    "golang.org/x/text/unicode/norm"
+
+    "github.com/ImanolCE/api-rest-go/config"
+)
+
+// ValidarPassword revisa que una contraseña nueva cumpla con la política de config
+func ValidarPassword(password string) error {
+    if len([]rune(password)) < config.PasswordLongitudMinima {
+        return fmt.Errorf("la contraseña debe tener al menos %d caracteres", config.PasswordLongitudMinima)
+    }
+
+    var mayuscula, minuscula, numero, simbolo bool
+    for _, r := range password {
+        switch {
+        case unicode.IsUpper(r):
+            mayuscula = true
+        case unicode.IsLower(r):
+            minuscula = true
+        case unicode.IsDigit(r):
+            numero = true
+        case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+            simbolo = true
+        }
+    }
+    if config.PasswordRequiereMayusculas && !(mayuscula && minuscula) {
+        return errors.New("la contraseña debe tener mayúsculas y minúsculas")
+    }
+    if config.PasswordRequiereNumero && !numero {
+        return errors.New("la contraseña debe tener al menos un número")
+    }
+    if config.PasswordRequiereSimbolo && !simbolo {
+        return errors.New("la contraseña debe tener al menos un símbolo")
+    }
+    return nil
+}
+
+// NormalizarRespuesta deja la respuesta secreta en minúsculas, sin acentos y sin espacios
+// repetidos, asi "  Ciudad de México" y "ciudad de mexico" se consideran la misma respuesta
+func NormalizarRespuesta(respuesta string) string {
+    t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+    limpia, _, err := transform.String(t, respuesta)
+    if err != nil {
+        limpia = respuesta
+    }
+    return strings.Join(strings.Fields(strings.ToLower(limpia)), " ")
+}
+
+// HashRespuesta normaliza la respuesta secreta y la hashea con bcrypt, igual que las contraseñas
+func HashRespuesta(respuesta string) (string, error) {
+    hash, err := bcrypt.GenerateFromPassword([]byte(NormalizarRespuesta(respuesta)), bcrypt.DefaultCost)
+    if err != nil {
+        return "", err
+    }
+    return string(hash), nil
+}
+
+// CompararRespuesta verifica una respuesta contra la guardada. Las cuentas viejas todavía
+// tienen la respuesta en texto plano, en ese caso se compara la versión normalizada
+func CompararRespuesta(guardada, respuesta string) bool {
+    if EsHashBcrypt(guardada) {
+        return bcrypt.CompareHashAndPassword([]byte(guardada), []byte(NormalizarRespuesta(respuesta))) == nil
+    }
+    return guardada != "" && NormalizarRespuesta(guardada) == NormalizarRespuesta(respuesta)
+}
+
+// EsHashBcrypt indica si el valor ya es un hash de bcrypt
+func EsHashBcrypt(valor string) bool {
+    _, err := bcrypt.Cost([]byte(valor))
+    return err == nil
+}
@@ -0,0 +1,172 @@
+// utils/revocacion.go
+package utils
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "github.com/ImanolCE/api-rest-go/config"
+)
+
+// TTLCacheRevocacion es cuanto tiempo se confía en una consulta negativa (token no revocado
+// o sesión del usuario) antes de volver a preguntar a Mongo. Las revocaciones hechas en esta
+// misma instancia se reflejan al momento, las de otras instancias tardan como máximo esto
+const TTLCacheRevocacion = 15 * time.Second
+
+// entradaCache guarda el resultado de una consulta y hasta cuando es válido
+type entradaCache struct {
+    revocado bool
+    sesion   Sesion
+    hasta    time.Time
+}
+
+// cacheRevocacion es el cache en memoria de jti revocados y de la sesión (versión de tokens y estado) por usuario
+type cacheRevocacion struct {
+    mu        sync.RWMutex
+    jtis      map[string]entradaCache
+    versiones map[string]entradaCache
+}
+
+var cache = &cacheRevocacion{
+    jtis:      map[string]entradaCache{},
+    versiones: map[string]entradaCache{},
+}
+
+func (c *cacheRevocacion) get(m map[string]entradaCache, clave string) (entradaCache, bool) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    e, ok := m[clave]
+    if !ok || time.Now().After(e.hasta) {
+        return entradaCache{}, false
+    }
+    return e, true
+}
+
+func (c *cacheRevocacion) set(m map[string]entradaCache, clave string, e entradaCache) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    // limpieza sencilla para que el mapa no crezca sin límite
+    if len(m) > 10000 {
+        ahora := time.Now()
+        for k, v := range m {
+            if ahora.After(v.hasta) {
+                delete(m, k)
+            }
+        }
+    }
+    m[clave] = e
+}
+
+// getCollectionRevokedTokens devuelve la colección "revoked_tokens"
+func getCollectionRevokedTokens() *mongo.Collection {
+    return config.ClientMongo.Database(config.DBName).Collection("revoked_tokens")
+}
+
+// CrearIndicesRevocacion crea el índice TTL, Mongo borra la entrada cuando el token ya expiró solo
+func CrearIndicesRevocacion(ctx context.Context) error {
+    _, err := getCollectionRevokedTokens().Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "expira_en", Value: 1}},
+        Options: options.Index().SetExpireAfterSeconds(0),
+    })
+    return err
+}
+
+// RevocarToken agrega el jti a la lista de tokens revocados hasta que el token expire
+func RevocarToken(ctx context.Context, claims *ClaimsPersonalizados) error {
+    expira := time.Now().Add(DuracionAccessToken)
+    if claims.ExpiresAt != nil {
+        expira = claims.ExpiresAt.Time
+    }
+
+    doc := bson.M{"usuario_id": claims.UserID, "expira_en": expira, "revocado_en": time.Now()}
+    opts := options.Update().SetUpsert(true)
+    if _, err := getCollectionRevokedTokens().UpdateOne(ctx, bson.M{"_id": claims.ID}, bson.M{"$set": doc}, opts); err != nil {
+        return err
+    }
+
+    cache.set(cache.jtis, claims.ID, entradaCache{revocado: true, hasta: expira})
+    return nil
+}
+
+// TokenRevocado indica si el jti está en la lista de revocados
+func TokenRevocado(ctx context.Context, jti string) (bool, error) {
+    if e, ok := cache.get(cache.jtis, jti); ok {
+        return e.revocado, nil
+    }
+
+    var doc struct {
+        ExpiraEn time.Time `bson:"expira_en"`
+    }
+    err := getCollectionRevokedTokens().FindOne(ctx, bson.M{"_id": jti}).Decode(&doc)
+    if err == mongo.ErrNoDocuments {
+        cache.set(cache.jtis, jti, entradaCache{revocado: false, hasta: time.Now().Add(TTLCacheRevocacion)})
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    cache.set(cache.jtis, jti, entradaCache{revocado: true, hasta: doc.ExpiraEn})
+    return true, nil
+}
+
+// Sesion es lo que el middleware necesita saber del usuario en cada petición: la versión de
+// tokens vigente (campo "token_version"), el estado de la cuenta y si el email está verificado
+type Sesion struct {
+    Version         int    `bson:"token_version"`
+    Estado          string `bson:"status"`
+    EmailVerificado bool   `bson:"email_verificado"`
+}
+
+// proyeccionSesion son los campos del usuario que forman la Sesion
+var proyeccionSesion = bson.M{"token_version": 1, "status": 1, "email_verificado": 1}
+
+// EstadoSesion regresa la sesión vigente del usuario, las cuentas eliminadas no tienen sesión
+func EstadoSesion(ctx context.Context, userID string) (Sesion, error) {
+    if e, ok := cache.get(cache.versiones, userID); ok {
+        return e.sesion, nil
+    }
+
+    objID, err := primitive.ObjectIDFromHex(userID)
+    if err != nil {
+        return Sesion{}, err
+    }
+
+    var sesion Sesion
+    users := config.ClientMongo.Database(config.DBName).Collection("users")
+    opts := options.FindOne().SetProjection(proyeccionSesion)
+    if err := users.FindOne(ctx, bson.M{"_id": objID, "deleted_at": nil}, opts).Decode(&sesion); err != nil {
+        return Sesion{}, err
+    }
+
+    cache.set(cache.versiones, userID, entradaCache{sesion: sesion, hasta: time.Now().Add(TTLCacheRevocacion)})
+    return sesion, nil
+}
+
+// RevocarTokensUsuario aumenta la versión de tokens del usuario, con eso todos los
+// JWT emitidos antes dejan de ser aceptados por el middleware
+func RevocarTokensUsuario(ctx context.Context, userID primitive.ObjectID) error {
+    var sesion Sesion
+    users := config.ClientMongo.Database(config.DBName).Collection("users")
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(proyeccionSesion)
+    err := users.FindOneAndUpdate(ctx, bson.M{"_id": userID}, bson.M{"$inc": bson.M{"token_version": 1}}, opts).Decode(&sesion)
+    if err != nil {
+        return err
+    }
+
+    cache.set(cache.versiones, userID.Hex(), entradaCache{sesion: sesion, hasta: time.Now().Add(TTLCacheRevocacion)})
+    return nil
+}
+
+// OlvidarSesion quita la sesión del usuario del cache, así la siguiente petición lee de la BD un
+// cambio que no pasa por la versión de tokens, como el email verificado
+func OlvidarSesion(userID string) {
+    cache.mu.Lock()
+    defer cache.mu.Unlock()
+    delete(cache.versiones, userID)
+}
@@ -0,0 +1,349 @@
+// utils/rrule.go
+package utils
+
+import (
+    "errors"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Frecuencias de RRULE que se soportan
+const (
+    FrecuenciaDiaria  = "DAILY"
+    FrecuenciaSemanal = "WEEKLY"
+    FrecuenciaMensual = "MONTHLY"
+    FrecuenciaAnual   = "YEARLY"
+)
+
+// Límites de una regla, para que una serie no genere ocurrencias sin fin al expandirla
+const (
+    MaxIntervaloRegla = 1000
+    MaxCuentaRegla    = 5000
+    maxPeriodosRegla  = 50000
+)
+
+// formatoLocalRegla es la fecha y hora local de un UNTIL flotante, también sirve para comparar horas locales
+const formatoLocalRegla = "20060102T150405"
+
+// diasRegla son los días de BYDAY
+var diasRegla = map[string]time.Weekday{
+    "SU": time.Sunday,
+    "MO": time.Monday,
+    "TU": time.Tuesday,
+    "WE": time.Wednesday,
+    "TH": time.Thursday,
+    "FR": time.Friday,
+    "SA": time.Saturday,
+}
+
+// DiaRegla es un valor de BYDAY. Ordinal solo se usa con MONTHLY: 1MO es el primer lunes del mes,
+// -1FR el último viernes y 0 (sin número) todos los de ese día
+type DiaRegla struct {
+    Ordinal int
+    Dia     time.Weekday
+}
+
+// Regla es un subconjunto de RRULE (RFC 5545): FREQ, INTERVAL, BYDAY, COUNT y UNTIL
+type Regla struct {
+    Frecuencia string
+    Intervalo  int
+    Dias       []DiaRegla
+    Cuenta     int        // 0 = sin límite de ocurrencias
+    Hasta      *time.Time // inclusivo, en UTC
+}
+
+// ParsearRegla lee una regla como "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10". Un UNTIL sin Z
+// o solo con la fecha se interpreta en loc, solo con la fecha incluye todo ese día
+func ParsearRegla(texto string, loc *time.Location) (Regla, error) {
+    r := Regla{Intervalo: 1}
+    texto = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(texto)), "RRULE:")
+    if texto == "" {
+        return r, errors.New("la regla está vacía")
+    }
+
+    vistos := map[string]bool{}
+    for _, parte := range strings.Split(texto, ";") {
+        clave, valor, ok := strings.Cut(parte, "=")
+        if !ok || valor == "" {
+            return r, errors.New("parte inválida: " + parte)
+        }
+        if vistos[clave] {
+            return r, errors.New(clave + " está repetido")
+        }
+        vistos[clave] = true
+
+        switch clave {
+        case "FREQ":
+            switch valor {
+            case FrecuenciaDiaria, FrecuenciaSemanal, FrecuenciaMensual, FrecuenciaAnual:
+                r.Frecuencia = valor
+            default:
+                return r, errors.New("FREQ debe ser DAILY, WEEKLY, MONTHLY o YEARLY")
+            }
+        case "INTERVAL":
+            n, err := strconv.Atoi(valor)
+            if err != nil || n < 1 || n > MaxIntervaloRegla {
+                return r, errors.New("INTERVAL debe ser un número entre 1 y " + strconv.Itoa(MaxIntervaloRegla))
+            }
+            r.Intervalo = n
+        case "COUNT":
+            n, err := strconv.Atoi(valor)
+            if err != nil || n < 1 || n > MaxCuentaRegla {
+                return r, errors.New("COUNT debe ser un número entre 1 y " + strconv.Itoa(MaxCuentaRegla))
+            }
+            r.Cuenta = n
+        case "UNTIL":
+            hasta, err := parsearHasta(valor, loc)
+            if err != nil {
+                return r, err
+            }
+            r.Hasta = &hasta
+        case "BYDAY":
+            for _, d := range strings.Split(valor, ",") {
+                dia, err := parsearDiaRegla(d)
+                if err != nil {
+                    return r, err
+                }
+                r.Dias = append(r.Dias, dia)
+            }
+        default:
+            return r, errors.New(clave + " no está soportado")
+        }
+    }
+
+    if r.Frecuencia == "" {
+        return r, errors.New("falta FREQ")
+    }
+    if r.Cuenta > 0 && r.Hasta != nil {
+        return r, errors.New("COUNT y UNTIL no se pueden usar juntos")
+    }
+    for _, d := range r.Dias {
+        if d.Ordinal != 0 && r.Frecuencia != FrecuenciaMensual {
+            return r, errors.New("BYDAY con número solo se puede usar con FREQ=MONTHLY")
+        }
+    }
+    if len(r.Dias) > 0 && r.Frecuencia == FrecuenciaAnual {
+        return r, errors.New("BYDAY no se puede usar con FREQ=YEARLY")
+    }
+    return r, nil
+}
+
+// parsearHasta lee UNTIL en formato 20060102T150405Z, 20060102T150405 (en loc) o 20060102
+func parsearHasta(valor string, loc *time.Location) (time.Time, error) {
+    if t, err := time.Parse("20060102T150405Z", valor); err == nil {
+        return t.UTC(), nil
+    }
+    if t, err := time.ParseInLocation(formatoLocalRegla, valor, loc); err == nil {
+        return t.UTC(), nil
+    }
+    if t, err := time.ParseInLocation("20060102", valor, loc); err == nil {
+        return t.AddDate(0, 0, 1).Add(-time.Second).UTC(), nil
+    }
+    return time.Time{}, errors.New("UNTIL inválido (formato 20060102T150405Z o 20060102)")
+}
+
+// parsearDiaRegla lee un valor de BYDAY como MO, 1MO o -1FR
+func parsearDiaRegla(valor string) (DiaRegla, error) {
+    var d DiaRegla
+    if len(valor) < 2 {
+        return d, errors.New("BYDAY inválido: " + valor)
+    }
+    dia, ok := diasRegla[valor[len(valor)-2:]]
+    if !ok {
+        return d, errors.New("BYDAY inválido: " + valor)
+    }
+    d.Dia = dia
+    if numero := valor[:len(valor)-2]; numero != "" {
+        n, err := strconv.Atoi(numero)
+        if err != nil || n == 0 || n < -5 || n > 5 {
+            return d, errors.New("BYDAY inválido: " + valor)
+        }
+        d.Ordinal = n
+    }
+    return d, nil
+}
+
+// String regresa la regla en su forma normalizada
+func (r Regla) String() string {
+    partes := []string{"FREQ=" + r.Frecuencia}
+    if r.Intervalo > 1 {
+        partes = append(partes, "INTERVAL="+strconv.Itoa(r.Intervalo))
+    }
+    if len(r.Dias) > 0 {
+        dias := make([]string, len(r.Dias))
+        for i, d := range r.Dias {
+            for nombre, dia := range diasRegla {
+                if dia == d.Dia {
+                    dias[i] = nombre
+                }
+            }
+            if d.Ordinal != 0 {
+                dias[i] = strconv.Itoa(d.Ordinal) + dias[i]
+            }
+        }
+        partes = append(partes, "BYDAY="+strings.Join(dias, ","))
+    }
+    if r.Cuenta > 0 {
+        partes = append(partes, "COUNT="+strconv.Itoa(r.Cuenta))
+    }
+    if r.Hasta != nil {
+        partes = append(partes, "UNTIL="+r.Hasta.UTC().Format("20060102T150405Z"))
+    }
+    return strings.Join(partes, ";")
+}
+
+// Recorrer llama f con el inicio (en UTC) de cada ocurrencia de la serie que empieza en inicio,
+// en orden, hasta que se acaben las ocurrencias o f regrese false. Los días y la hora se cuentan
+// en loc, así una task semanal a las 9:00 sigue a las 9:00 locales después del cambio de horario.
+// Si inicio no cumple la regla no es una ocurrencia, la primera es la siguiente que sí la cumple
+func (r Regla) Recorrer(inicio time.Time, loc *time.Location, f func(time.Time) bool) {
+    dtstart := inicio.In(loc)
+    n := 0
+    for periodo := 0; periodo < maxPeriodosRegla; periodo++ {
+        for _, oc := range r.candidatos(dtstart, periodo, loc) {
+            // En la hora que se repite al atrasar el reloj el candidato del día de dtstart puede
+            // quedar una hora antes aunque marque la misma hora local, sigue siendo dtstart
+            if oc.Before(dtstart) && oc.Format(formatoLocalRegla) == dtstart.Format(formatoLocalRegla) {
+                oc = dtstart
+            }
+            if oc.Before(dtstart) {
+                continue
+            }
+            if r.Hasta != nil && oc.After(*r.Hasta) {
+                return
+            }
+            if r.Cuenta > 0 && n >= r.Cuenta {
+                return
+            }
+            n++
+            if !f(oc.UTC()) {
+                return
+            }
+        }
+    }
+}
+
+// Dividir parte la serie que empieza en inicio justo antes de la ocurrencia corte. anterior
+// termina un segundo antes de corte con UNTIL; siguiente es la regla de la serie que empieza en
+// corte y se queda con el COUNT que faltaba, contando también las ocurrencias que se quitaron
+// porque COUNT las cuenta
+func (r Regla) Dividir(inicio, corte time.Time, loc *time.Location) (anterior, siguiente Regla) {
+    antes := 0
+    r.Recorrer(inicio, loc, func(oc time.Time) bool {
+        if !oc.Before(corte) {
+            return false
+        }
+        antes++
+        return true
+    })
+
+    siguiente = r
+    if r.Cuenta > 0 {
+        siguiente.Cuenta = r.Cuenta - antes
+    }
+    hasta := corte.Add(-time.Second).UTC()
+    anterior = r
+    anterior.Cuenta = 0
+    anterior.Hasta = &hasta
+    return anterior, siguiente
+}
+
+// candidatos regresa las posibles ocurrencias del periodo número periodo (día, semana, mes o año
+// según FREQ), en orden y con la hora de dtstart
+func (r Regla) candidatos(dtstart time.Time, periodo int, loc *time.Location) []time.Time {
+    en := func(anio int, mes time.Month, dia int) time.Time {
+        return time.Date(anio, mes, dia, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), loc)
+    }
+    salto := periodo * r.Intervalo
+
+    switch r.Frecuencia {
+    case FrecuenciaDiaria:
+        dia := en(dtstart.Year(), dtstart.Month(), dtstart.Day()+salto)
+        if len(r.Dias) > 0 && !r.incluyeDia(dia.Weekday()) {
+            return nil
+        }
+        return []time.Time{dia}
+
+    case FrecuenciaSemanal:
+        // Las semanas empiezan en lunes, como WKST por defecto de RFC 5545
+        lunes := dtstart.Day() - (int(dtstart.Weekday())+6)%7 + 7*salto
+        dias := r.Dias
+        if len(dias) == 0 {
+            dias = []DiaRegla{{Dia: dtstart.Weekday()}}
+        }
+        var lista []time.Time
+        for _, d := range dias {
+            lista = append(lista, en(dtstart.Year(), dtstart.Month(), lunes+(int(d.Dia)+6)%7))
+        }
+        return ordenarUnicas(lista)
+
+    case FrecuenciaMensual:
+        primero := time.Date(dtstart.Year(), dtstart.Month()+time.Month(salto), 1, 0, 0, 0, 0, loc)
+        anio, mes := primero.Year(), primero.Month()
+        diasMes := time.Date(anio, mes+1, 0, 0, 0, 0, 0, loc).Day()
+        if len(r.Dias) == 0 {
+            // Los meses que no tienen ese día (31 en abril) no tienen ocurrencia
+            if dtstart.Day() > diasMes {
+                return nil
+            }
+            return []time.Time{en(anio, mes, dtstart.Day())}
+        }
+        var lista []time.Time
+        for _, d := range r.Dias {
+            primerDia := 1 + (int(d.Dia)-int(primero.Weekday())+7)%7
+            var dias []int
+            for dia := primerDia; dia <= diasMes; dia += 7 {
+                dias = append(dias, dia)
+            }
+            switch {
+            case d.Ordinal == 0:
+                for _, dia := range dias {
+                    lista = append(lista, en(anio, mes, dia))
+                }
+            case d.Ordinal > 0 && d.Ordinal <= len(dias):
+                lista = append(lista, en(anio, mes, dias[d.Ordinal-1]))
+            case d.Ordinal < 0 && -d.Ordinal <= len(dias):
+                lista = append(lista, en(anio, mes, dias[len(dias)+d.Ordinal]))
+            }
+        }
+        return ordenarUnicas(lista)
+
+    case FrecuenciaAnual:
+        fecha := en(dtstart.Year()+salto, dtstart.Month(), dtstart.Day())
+        // El 29 de febrero solo ocurre en años bisiestos
+        if fecha.Month() != dtstart.Month() {
+            return nil
+        }
+        return []time.Time{fecha}
+    }
+    return nil
+}
+
+// incluyeDia indica si el día de la semana está en BYDAY
+func (r Regla) incluyeDia(dia time.Weekday) bool {
+    for _, d := range r.Dias {
+        if d.Dia == dia {
+            return true
+        }
+    }
+    return false
+}
+
+// ordenarUnicas ordena las fechas y quita las repetidas
+func ordenarUnicas(lista []time.Time) []time.Time {
+    sort.Slice(lista, func(i, j int) bool { return lista[i].Before(lista[j]) })
+    unicas := lista[:0]
+    for i, t := range lista {
+        if i == 0 || !t.Equal(lista[i-1]) {
+            unicas = append(unicas, t)
+        }
+    }
+    return unicas
+}
+
+// Finita indica si la serie tiene un número limitado de ocurrencias
+func (r Regla) Finita() bool {
+    return r.Cuenta > 0 || r.Hasta != nil
+}
@@ -0,0 +1,304 @@
+// utils/rrule_test.go
+package utils
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+// formatoPrueba muestra la fecha en la zona de la serie, con la abreviatura para ver el cambio de horario
+const formatoPrueba = "2006-01-02 15:04 MST"
+
+func zonaPrueba(t *testing.T, nombre string) *time.Location {
+    t.Helper()
+    loc, err := time.LoadLocation(nombre)
+    if err != nil {
+        t.Fatalf("no se pudo cargar la zona %s: %v", nombre, err)
+    }
+    return loc
+}
+
+// recorrerPrueba regresa hasta max ocurrencias de la serie formateadas en loc
+func recorrerPrueba(r Regla, inicio time.Time, loc *time.Location, max int) []string {
+    lista := []string{}
+    r.Recorrer(inicio, loc, func(oc time.Time) bool {
+        lista = append(lista, oc.In(loc).Format(formatoPrueba))
+        return len(lista) < max
+    })
+    return lista
+}
+
+func TestParsearRegla(t *testing.T) {
+    ny := zonaPrueba(t, "America/New_York")
+    casos := []struct {
+        nombre  string
+        texto   string
+        loc     *time.Location
+        esperan string // forma normalizada, vacío si tiene que fallar
+    }{
+        {"semanal completa", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10", time.UTC, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10"},
+        {"prefijo y minúsculas", "rrule:freq=daily;interval=1", time.UTC, "FREQ=DAILY"},
+        {"espacios alrededor", "  FREQ=YEARLY  ", time.UTC, "FREQ=YEARLY"},
+        {"último viernes", "FREQ=MONTHLY;BYDAY=-1FR", time.UTC, "FREQ=MONTHLY;BYDAY=-1FR"},
+        {"quinto lunes", "FREQ=MONTHLY;BYDAY=5MO", time.UTC, "FREQ=MONTHLY;BYDAY=5MO"},
+        {"diaria con BYDAY", "FREQ=DAILY;BYDAY=MO,FR", time.UTC, "FREQ=DAILY;BYDAY=MO,FR"},
+        {"UNTIL en UTC", "FREQ=DAILY;UNTIL=20250310T140000Z", ny, "FREQ=DAILY;UNTIL=20250310T140000Z"},
+        {"UNTIL flotante en la zona", "FREQ=DAILY;UNTIL=20250101T090000", ny, "FREQ=DAILY;UNTIL=20250101T140000Z"},
+        {"UNTIL solo fecha incluye el día", "FREQ=DAILY;UNTIL=20250310", ny, "FREQ=DAILY;UNTIL=20250311T035959Z"},
+        {"vacía", "", time.UTC, ""},
+        {"solo prefijo", "RRULE:", time.UTC, ""},
+        {"sin FREQ", "INTERVAL=2", time.UTC, ""},
+        {"FREQ no soportada", "FREQ=HOURLY", time.UTC, ""},
+        {"clave repetida", "FREQ=DAILY;FREQ=WEEKLY", time.UTC, ""},
+        {"clave no soportada", "FREQ=DAILY;BYSETPOS=1", time.UTC, ""},
+        {"parte sin valor", "FREQ=DAILY;COUNT=", time.UTC, ""},
+        {"INTERVAL cero", "FREQ=DAILY;INTERVAL=0", time.UTC, ""},
+        {"INTERVAL demasiado grande", "FREQ=DAILY;INTERVAL=1001", time.UTC, ""},
+        {"COUNT cero", "FREQ=DAILY;COUNT=0", time.UTC, ""},
+        {"COUNT demasiado grande", "FREQ=DAILY;COUNT=5001", time.UTC, ""},
+        {"COUNT y UNTIL", "FREQ=DAILY;COUNT=2;UNTIL=20250101", time.UTC, ""},
+        {"UNTIL inválido", "FREQ=DAILY;UNTIL=2025-01-01", time.UTC, ""},
+        {"día inválido", "FREQ=WEEKLY;BYDAY=XX", time.UTC, ""},
+        {"ordinal fuera de rango", "FREQ=MONTHLY;BYDAY=6MO", time.UTC, ""},
+        {"ordinal cero", "FREQ=MONTHLY;BYDAY=0MO", time.UTC, ""},
+        {"ordinal sin MONTHLY", "FREQ=WEEKLY;BYDAY=1MO", time.UTC, ""},
+        {"BYDAY con YEARLY", "FREQ=YEARLY;BYDAY=MO", time.UTC, ""},
+    }
+    for _, c := range casos {
+        t.Run(c.nombre, func(t *testing.T) {
+            r, err := ParsearRegla(c.texto, c.loc)
+            if c.esperan == "" {
+                if err == nil {
+                    t.Fatalf("se esperaba error, se obtuvo %q", r.String())
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("error inesperado: %v", err)
+            }
+            if got := r.String(); got != c.esperan {
+                t.Fatalf("String() = %q, se esperaba %q", got, c.esperan)
+            }
+        })
+    }
+}
+
+func TestReglaStringIdaYVuelta(t *testing.T) {
+    ny := zonaPrueba(t, "America/New_York")
+    textos := []string{
+        "FREQ=DAILY",
+        "FREQ=DAILY;INTERVAL=3;COUNT=7",
+        "FREQ=WEEKLY;BYDAY=SU,SA",
+        "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR;UNTIL=20251231T235959Z",
+        "FREQ=MONTHLY;BYDAY=1MO,-1FR,TU",
+        "FREQ=MONTHLY;INTERVAL=6;COUNT=4",
+        "FREQ=YEARLY;UNTIL=20300101T000000Z",
+    }
+    for _, texto := range textos {
+        t.Run(texto, func(t *testing.T) {
+            r, err := ParsearRegla(texto, ny)
+            if err != nil {
+                t.Fatalf("error inesperado: %v", err)
+            }
+            if got := r.String(); got != texto {
+                t.Fatalf("String() = %q, se esperaba %q", got, texto)
+            }
+            // Leer la forma normalizada en otra zona da la misma regla, UNTIL ya va en UTC
+            otra, err := ParsearRegla(r.String(), time.UTC)
+            if err != nil {
+                t.Fatalf("error al volver a leer: %v", err)
+            }
+            if !reflect.DeepEqual(otra, r) {
+                t.Fatalf("al volver a leer se obtuvo %+v, se esperaba %+v", otra, r)
+            }
+        })
+    }
+}
+
+func TestRecorrer(t *testing.T) {
+    ny := zonaPrueba(t, "America/New_York")
+    casos := []struct {
+        nombre  string
+        regla   string
+        inicio  time.Time
+        loc     *time.Location
+        max     int
+        esperan []string
+    }{
+        {
+            "la hora local se mantiene al cambiar de horario",
+            "FREQ=WEEKLY;BYDAY=MO,WE,FR", time.Date(2025, 3, 5, 9, 0, 0, 0, ny), ny, 5,
+            []string{"2025-03-05 09:00 EST", "2025-03-07 09:00 EST", "2025-03-10 09:00 EDT", "2025-03-12 09:00 EDT", "2025-03-14 09:00 EDT"},
+        },
+        {
+            "diaria al atrasar el reloj",
+            "FREQ=DAILY", time.Date(2025, 11, 1, 9, 0, 0, 0, ny), ny, 3,
+            []string{"2025-11-01 09:00 EDT", "2025-11-02 09:00 EST", "2025-11-03 09:00 EST"},
+        },
+        {
+            "inicio en la hora repetida al atrasar el reloj",
+            "FREQ=DAILY", time.Date(2025, 11, 2, 6, 30, 0, 0, time.UTC), ny, 2,
+            []string{"2025-11-02 01:30 EST", "2025-11-03 01:30 EST"},
+        },
+        {
+            "semanal cada dos semanas",
+            "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", time.Date(2025, 3, 6, 10, 0, 0, 0, time.UTC), time.UTC, 5,
+            []string{"2025-03-06 10:00 UTC", "2025-03-17 10:00 UTC", "2025-03-20 10:00 UTC", "2025-03-31 10:00 UTC", "2025-04-03 10:00 UTC"},
+        },
+        {
+            "semanal sin BYDAY usa el día de inicio",
+            "FREQ=WEEKLY", time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC), time.UTC, 3,
+            []string{"2025-03-04 08:00 UTC", "2025-03-11 08:00 UTC", "2025-03-18 08:00 UTC"},
+        },
+        {
+            "inicio que no cumple BYDAY no es ocurrencia",
+            "FREQ=WEEKLY;BYDAY=MO", time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC), time.UTC, 2,
+            []string{"2025-03-10 08:00 UTC", "2025-03-17 08:00 UTC"},
+        },
+        {
+            "diaria con BYDAY",
+            "FREQ=DAILY;BYDAY=MO,FR", time.Date(2025, 3, 3, 8, 0, 0, 0, time.UTC), time.UTC, 3,
+            []string{"2025-03-03 08:00 UTC", "2025-03-07 08:00 UTC", "2025-03-10 08:00 UTC"},
+        },
+        {
+            "mensual salta los meses sin el día",
+            "FREQ=MONTHLY", time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC), time.UTC, 4,
+            []string{"2025-01-31 12:00 UTC", "2025-03-31 12:00 UTC", "2025-05-31 12:00 UTC", "2025-07-31 12:00 UTC"},
+        },
+        {
+            "último viernes del mes",
+            "FREQ=MONTHLY;BYDAY=-1FR", time.Date(2025, 3, 28, 18, 0, 0, 0, time.UTC), time.UTC, 4,
+            []string{"2025-03-28 18:00 UTC", "2025-04-25 18:00 UTC", "2025-05-30 18:00 UTC", "2025-06-27 18:00 UTC"},
+        },
+        {
+            "quinto lunes solo en los meses que lo tienen",
+            "FREQ=MONTHLY;BYDAY=5MO", time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC), time.UTC, 4,
+            []string{"2025-03-31 09:00 UTC", "2025-06-30 09:00 UTC", "2025-09-29 09:00 UTC", "2025-12-29 09:00 UTC"},
+        },
+        {
+            "primer y tercer lunes con COUNT",
+            "FREQ=MONTHLY;BYDAY=1MO,3MO;COUNT=5", time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC), time.UTC, 10,
+            []string{"2025-03-17 09:00 UTC", "2025-04-07 09:00 UTC", "2025-04-21 09:00 UTC", "2025-05-05 09:00 UTC", "2025-05-19 09:00 UTC"},
+        },
+        {
+            "29 de febrero solo en años bisiestos",
+            "FREQ=YEARLY", time.Date(2024, 2, 29, 7, 0, 0, 0, time.UTC), time.UTC, 3,
+            []string{"2024-02-29 07:00 UTC", "2028-02-29 07:00 UTC", "2032-02-29 07:00 UTC"},
+        },
+        {
+            "COUNT limita las ocurrencias",
+            "FREQ=DAILY;COUNT=3", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), time.UTC, 10,
+            []string{"2025-01-01 09:00 UTC", "2025-01-02 09:00 UTC", "2025-01-03 09:00 UTC"},
+        },
+        {
+            "UNTIL es inclusivo",
+            "FREQ=DAILY;UNTIL=20250103T090000Z", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), time.UTC, 10,
+            []string{"2025-01-01 09:00 UTC", "2025-01-02 09:00 UTC", "2025-01-03 09:00 UTC"},
+        },
+        {
+            "UNTIL solo fecha incluye todo ese día local",
+            "FREQ=DAILY;UNTIL=20250103", time.Date(2025, 1, 1, 21, 0, 0, 0, ny), ny, 10,
+            []string{"2025-01-01 21:00 EST", "2025-01-02 21:00 EST", "2025-01-03 21:00 EST"},
+        },
+        {
+            "UNTIL antes del inicio no genera ocurrencias",
+            "FREQ=DAILY;UNTIL=20241231", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), time.UTC, 10,
+            []string{},
+        },
+    }
+    for _, c := range casos {
+        t.Run(c.nombre, func(t *testing.T) {
+            r, err := ParsearRegla(c.regla, c.loc)
+            if err != nil {
+                t.Fatalf("error inesperado: %v", err)
+            }
+            got := recorrerPrueba(r, c.inicio, c.loc, c.max)
+            if !reflect.DeepEqual(got, c.esperan) {
+                t.Fatalf("Recorrer() = %v, se esperaba %v", got, c.esperan)
+            }
+        })
+    }
+}
+
+func TestRecorrerDetenerse(t *testing.T) {
+    r, err := ParsearRegla("FREQ=DAILY", time.UTC)
+    if err != nil {
+        t.Fatalf("error inesperado: %v", err)
+    }
+    llamadas := 0
+    r.Recorrer(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.UTC, func(time.Time) bool {
+        llamadas++
+        return false
+    })
+    if llamadas != 1 {
+        t.Fatalf("f se llamó %d veces después de regresar false", llamadas)
+    }
+}
+
+func TestDividir(t *testing.T) {
+    ny := zonaPrueba(t, "America/New_York")
+    casos := []struct {
+        nombre    string
+        regla     string
+        inicio    time.Time
+        corte     time.Time
+        loc       *time.Location
+        anterior  string
+        siguiente string
+    }{
+        {
+            "COUNT se reparte",
+            "FREQ=DAILY;COUNT=10", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2025, 1, 4, 9, 0, 0, 0, time.UTC), time.UTC,
+            "FREQ=DAILY;UNTIL=20250104T085959Z", "FREQ=DAILY;COUNT=7",
+        },
+        {
+            "COUNT con BYDAY",
+            "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6", time.Date(2025, 3, 3, 9, 0, 0, 0, ny), time.Date(2025, 3, 12, 9, 0, 0, 0, ny), ny,
+            "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20250312T125959Z", "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3",
+        },
+        {
+            "COUNT en el último",
+            "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", time.Date(2025, 3, 28, 18, 0, 0, 0, time.UTC), time.Date(2025, 5, 30, 18, 0, 0, 0, time.UTC), time.UTC,
+            "FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20250530T175959Z", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=1",
+        },
+        {
+            "sin fin",
+            "FREQ=WEEKLY;INTERVAL=2", time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC), time.UTC,
+            "FREQ=WEEKLY;INTERVAL=2;UNTIL=20250203T085959Z", "FREQ=WEEKLY;INTERVAL=2",
+        },
+        {
+            "UNTIL se conserva en la siguiente",
+            "FREQ=DAILY;UNTIL=20250110T090000Z", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2025, 1, 5, 9, 0, 0, 0, time.UTC), time.UTC,
+            "FREQ=DAILY;UNTIL=20250105T085959Z", "FREQ=DAILY;UNTIL=20250110T090000Z",
+        },
+    }
+    for _, c := range casos {
+        t.Run(c.nombre, func(t *testing.T) {
+            r, err := ParsearRegla(c.regla, c.loc)
+            if err != nil {
+                t.Fatalf("error inesperado: %v", err)
+            }
+            anterior, siguiente := r.Dividir(c.inicio, c.corte, c.loc)
+            if got := anterior.String(); got != c.anterior {
+                t.Fatalf("anterior = %q, se esperaba %q", got, c.anterior)
+            }
+            if got := siguiente.String(); got != c.siguiente {
+                t.Fatalf("siguiente = %q, se esperaba %q", got, c.siguiente)
+            }
+            if r.String() != c.regla {
+                t.Fatalf("Dividir cambió la regla original: %q", r.String())
+            }
+
+            // Las dos partes juntas son la serie original
+            if !r.Finita() {
+                return
+            }
+            completa := recorrerPrueba(r, c.inicio, c.loc, MaxCuentaRegla)
+            partes := append(recorrerPrueba(anterior, c.inicio, c.loc, MaxCuentaRegla), recorrerPrueba(siguiente, c.corte, c.loc, MaxCuentaRegla)...)
+            if !reflect.DeepEqual(partes, completa) {
+                t.Fatalf("las partes dan %v, la serie completa %v", partes, completa)
+            }
+        })
+    }
+}
@@ -0,0 +1,104 @@
+// utils/totp.go
+package utils
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Parámetros TOTP (RFC 6238), son los que usan Google Authenticator y compatibles
+const (
+    totpPeriodo = 30
+    totpDigitos = 6
+    // totpVentana es cuantos periodos antes y después se aceptan por desfase de reloj
+    totpVentana = 1
+)
+
+var base32SinPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerarSecretoTOTP crea un secreto aleatorio de 160 bits codificado en base32
+func GenerarSecretoTOTP() (string, error) {
+    b := make([]byte, 20)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return base32SinPadding.EncodeToString(b), nil
+}
+
+// URITOTP arma el URI otpauth:// que se convierte en QR para las apps de autenticación
+func URITOTP(emisor, cuenta, secreto string) string {
+    etiqueta := url.PathEscape(emisor + ":" + cuenta)
+    q := url.Values{}
+    q.Set("secret", secreto)
+    q.Set("issuer", emisor)
+    q.Set("algorithm", "SHA1")
+    q.Set("digits", fmt.Sprint(totpDigitos))
+    q.Set("period", fmt.Sprint(totpPeriodo))
+    return "otpauth://totp/" + etiqueta + "?" + q.Encode()
+}
+
+// codigoTOTP calcula el código HOTP (RFC 4226) del paso indicado
+func codigoTOTP(secreto []byte, paso int64) string {
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(paso))
+    mac := hmac.New(sha1.New, secreto)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    valor := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigitos, valor%1000000)
+}
+
+// VerificarTOTP revisa el código contra el secreto en el momento t. Regresa el paso que
+// coincidió para que el llamador pueda rechazar que el mismo código se use dos veces
+func VerificarTOTP(secreto, codigo string, t time.Time) (int64, bool) {
+    clave, err := base32SinPadding.DecodeString(strings.ToUpper(strings.TrimRight(secreto, "=")))
+    if err != nil {
+        return 0, false
+    }
+    codigo = strings.ReplaceAll(codigo, " ", "")
+    if len(codigo) != totpDigitos {
+        return 0, false
+    }
+
+    actual := t.Unix() / totpPeriodo
+    for i := -totpVentana; i <= totpVentana; i++ {
+        paso := actual + int64(i)
+        if subtle.ConstantTimeCompare([]byte(codigoTOTP(clave, paso)), []byte(codigo)) == 1 {
+            return paso, true
+        }
+    }
+    return 0, false
+}
+
+// GenerarCodigosRecuperacion crea n códigos de un solo uso con formato "xxxxx-xxxxx",
+// regresa los códigos en claro para mostrarlos una vez y sus hashes para guardarlos
+func GenerarCodigosRecuperacion(n int) ([]string, []string, error) {
+    codigos := make([]string, n)
+    hashes := make([]string, n)
+    for i := range codigos {
+        b := make([]byte, 7)
+        if _, err := rand.Read(b); err != nil {
+            return nil, nil, err
+        }
+        s := strings.ToLower(base32SinPadding.EncodeToString(b))[:10]
+        codigos[i] = s[:5] + "-" + s[5:]
+        hashes[i] = HashCodigoRecuperacion(codigos[i])
+    }
+    return codigos, hashes, nil
+}
+
+// HashCodigoRecuperacion normaliza un código de recuperación (sin guiones ni mayúsculas) y lo hashea
+func HashCodigoRecuperacion(codigo string) string {
+    codigo = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(codigo))
+    return HashToken(codigo)
+}